@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/Choplife-group/gomicrogen/internal/client"
+	"github.com/Choplife-group/gomicrogen/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+var (
+	clientService      string
+	clientEndpoint     string
+	clientCompileProto bool
+	clientGoMod        bool
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add components to a generated service",
+	Long: `Add components to an existing generated service.
+
+Run it from anywhere inside the service; the service is found through its
+.gomicrogen.json.`,
+}
+
+var addClientCmd = &cobra.Command{
+	Use:   "client <proto-or-service>",
+	Short: "Wire a typed gRPC client to an upstream service",
+	Long: `Wire a typed gRPC client to an upstream service.
+
+The argument is the upstream's .proto file, or another generated service: its
+directory or its .gomicrogen.json. For a service, the protos it serves are used
+and its gRPC port becomes the default endpoint.
+
+For a service named Wallet this:
+  • vendors the proto into app/grpc/wallet with go_package pointing there
+  • compiles it with buf or protoc (skip with --proto=false)
+  • writes FakeWalletClient, an in-memory client for tests
+  • adds WalletClient to App and controllers.Controller
+  • dials it once in Initialize through getGrpcConn, reading
+    WALLET_SERVICE_ENDPOINT
+  • adds WALLET_SERVICE_ENDPOINT to docker-compose-local.yml and
+    .env.example
+  • runs go mod tidy for the client's imports, when go is on the PATH
+
+Examples:
+  gomicrogen add client ../wallet-service
+  gomicrogen add client ~/protos/wallet-service.proto --endpoint wallet-service:81`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		manifestPath := manifest.Find(cwd)
+		if manifestPath == "" {
+			return fmt.Errorf("❌ No %s found in %s or its parents\n\n💡 Run this inside a service generated by gomicrogen", manifest.FileName, cwd)
+		}
+
+		m, err := manifest.Read(manifestPath)
+		if err != nil {
+			return err
+		}
+
+		serviceDir := filepath.Dir(manifestPath)
+
+		res, err := client.Add(client.Options{
+			ServiceDir: serviceDir,
+			ModuleName: m.ModuleName,
+			Source:     args[0],
+			Service:    clientService,
+			Endpoint:   clientEndpoint,
+		})
+		if errors.Is(err, client.ErrAlreadyWired) {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("❌ %w", err)
+		}
+		if err != nil {
+			return fmt.Errorf("❌ Failed to add the %s client: %w", args[0], err)
+		}
+
+		if !res.Vendored {
+			fmt.Printf("📁 Using the service's own %s\n", res.Proto)
+		}
+
+		for _, rel := range res.Files {
+			fmt.Printf("Updated: %s\n", filepath.Join(serviceDir, filepath.FromSlash(rel)))
+		}
+
+		if clientCompileProto {
+			if err := compileProtos(serviceDir); err != nil {
+				return fmt.Errorf("failed to compile protobuf: %w\n\n💡 Fix the proto and run make proto", err)
+			}
+		}
+
+		tidied := false
+		if _, err := exec.LookPath("go"); err == nil && clientGoMod {
+
+			fmt.Println("📁 Running go mod tidy...")
+
+			tidy := exec.Command("go", "mod", "tidy")
+			tidy.Dir = serviceDir
+			tidy.Stdout = os.Stdout
+			tidy.Stderr = os.Stderr
+
+			if err := tidy.Run(); err != nil {
+				return fmt.Errorf("failed to run go mod tidy: %w\n\n💡 The client is wired; fix the module and run go mod tidy in %s", err, serviceDir)
+			}
+
+			tidied = true
+		}
+
+		fmt.Printf("\n✅ Wired the %s client\n", res.Service)
+		fmt.Printf("   a.%s and Controller.%s dial %s (default %s)\n", res.Field, res.Field, res.EnvVar, res.Endpoint)
+		fmt.Printf("   Tests can use &%s{} in place of the real client\n", "Fake"+res.Service+"Client")
+		if !tidied {
+			fmt.Printf("   Run go mod tidy in %s for the client's imports\n", serviceDir)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+	addCmd.AddCommand(addClientCmd)
+
+	addClientCmd.Flags().StringVarP(&clientService, "service", "s", "", "Proto service to use when the source declares several")
+	addClientCmd.Flags().StringVarP(&clientEndpoint, "endpoint", "", "", "Endpoint for docker-compose-local.yml (default: the upstream's gRPC port on the docker host)")
+	addClientCmd.Flags().BoolVarP(&clientCompileProto, "proto", "", true, "Compile the vendored .proto with buf or protoc")
+	addClientCmd.Flags().BoolVarP(&clientGoMod, "go-mod", "", true, "Run go mod tidy for the client's imports")
+}
@@ -4,13 +4,16 @@ package cmd_test
 // the real templates, then assert on the generated tree.
 //
 // These are hermetic — no network, no docker — because every generation passes
-// --go-mod=false and --git=false. Compiling and running the generated services
-// lives in the separate test/e2e module.
+// --go-mod=false, --git=false and --proto=false. Compiling and running the
+// generated services lives in the separate test/e2e module.
 
 import (
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -57,6 +60,7 @@ func generate(t *testing.T, name string, args ...string) (string, string, error)
 		"--output-dir", out,
 		"--git=false",
 		"--go-mod=false",
+		"--proto=false",
 	}, args...)
 
 	cmd := exec.Command(binary, full...)
@@ -142,9 +146,11 @@ func TestEachTypeGeneratesItsOwnShape(t *testing.T) {
 				"main.go", "go.mod", "Dockerfile", "docker-compose-local.yml",
 				"app/router/router.go", "app/router/middleware.go", "app/router/status.go",
 				"app/controllers/controller.go", "app/database/database.go",
+				"app/idempotency/idempotency.go", "app/idempotency/grpc.go",
+				"app/signing/signing.go", "app/signing/http.go",
 			},
 			// general is HTTP only and has no upstream
-			wantAbsent: []string{"app/grpc", "app/queue", "app/publisher", "app/rabbitmq"},
+			wantAbsent: []string{"app/grpc", "app/queue", "app/publisher", "app/rabbitmq", "app/outbox"},
 		},
 		{
 			serviceType: "casino",
@@ -153,8 +159,9 @@ func TestEachTypeGeneratesItsOwnShape(t *testing.T) {
 				"app/grpc/casino/casino-service.proto",
 			},
 			wantAbsent: []string{
-				"app/queue", "app/publisher", "app/rabbitmq",
-				// generated protobuf is the developer's job, not the generator's
+				"app/queue", "app/publisher", "app/rabbitmq", "app/outbox",
+				"migrations/2_create_outbox.up.sql",
+				// only compiled when --proto is on
 				"app/grpc/casino/casino-service.pb.go",
 			},
 		},
@@ -164,7 +171,8 @@ func TestEachTypeGeneratesItsOwnShape(t *testing.T) {
 				"app/router/router.go",
 				"app/grpc/wallet/wallet-service.proto",
 				"app/publisher/publisher.go", "app/queue/queue.go",
-				"app/queue/consumer.go", "app/rabbitmq/rabbitmq.go",
+				"app/queue/consumer.go", "app/rabbitmq/rabbitmq.go", "app/rabbitmq/supervisor.go",
+				"app/outbox/outbox.go", "app/outbox/relay.go", "migrations/2_create_outbox.up.sql",
 			},
 			wantAbsent: []string{
 				"app/grpc/wallet/wallet-service.pb.go",
@@ -398,10 +406,10 @@ func TestFlagsReachGeneratedOutput(t *testing.T) {
 	}{
 		{"--description", "main.go", "SENTINEL_DESCRIPTION"},
 		{"--version", "main.go", "9.9.9"},
-		{"--env", "main.go", "SENTINEL_ENV"},
+		{"--env", "app/config/config.go", "SENTINEL_ENV"},
 		{"--port", "docker-compose-local.yml", "7777"},
 		{"--grpc-port", "docker-compose-local.yml", "7778"},
-		{"--grpc-port", "app/router/router.go", "7778"},
+		{"--grpc-port", "app/config/config.go", "7778"},
 		{"--db-host", "docker-compose-local.yml", "SENTINEL_DBHOST"},
 		{"--db-port", "docker-compose-local.yml", "7306"},
 		{"--db-password", "docker-compose-local.yml", "SENTINEL_DBPASS"},
@@ -634,6 +642,698 @@ func TestVersionSubcommand(t *testing.T) {
 	}
 }
 
+func TestDoctorSubcommandReportsTemplatesAndTypes(t *testing.T) {
+
+	cmd := exec.Command(binary, "doctor")
+	cmd.Dir = repoRoot
+
+	// the exit code depends on the tools installed on this machine, so only
+	// the report itself is asserted
+	out, _ := cmd.CombinedOutput()
+
+	for _, want := range []string{"CHECK", "nested layout", "type general", "type casino", "type payment", "go", "protoc"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("doctor output missing %q:\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "type ") && strings.Contains(line, "fail") {
+			t.Errorf("every shipped type must be valid: %s", line)
+		}
+	}
+}
+
+// --- plugins -----------------------------------------------------------------
+
+// writePlugin installs a shell-script plugin that echoes its arguments and the
+// context environment.
+func writePlugin(t *testing.T, dir, name string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins here are shell scripts")
+	}
+
+	script := `#!/bin/sh
+echo "args=$*"
+echo "templates=$GOMICROGEN_TEMPLATES_DIR"
+echo "version=$GOMICROGEN_VERSION"
+echo "manifest=$GOMICROGEN_MANIFEST"
+exit 7
+`
+	if err := os.WriteFile(filepath.Join(dir, "gomicrogen-"+name), []byte(script), 0o755); err != nil {
+		t.Fatalf("write plugin: %v", err)
+	}
+}
+
+func pluginEnv(dir string) []string {
+
+	return append(os.Environ(), "GOMICROGEN_PLUGIN_PATH="+dir, "HOME="+dir)
+}
+
+func TestUnknownSubcommandRunsPlugin(t *testing.T) {
+
+	plugins := t.TempDir()
+	writePlugin(t, plugins, "psp-onboard")
+
+	cmd := exec.Command(binary, "psp-onboard", "--provider", "pawapay")
+	cmd.Dir = repoRoot
+	cmd.Env = pluginEnv(plugins)
+
+	out, err := cmd.CombinedOutput()
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 7 {
+		t.Fatalf("the plugin's exit code must be passed through, got %v:\n%s", err, out)
+	}
+
+	for _, want := range []string{"args=--provider pawapay", "templates=" + filepath.Join(repoRoot, "templates"), "version=dev"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("plugin output missing %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(string(out), "manifest=\n") {
+		t.Errorf("outside a service no manifest must be passed:\n%s", out)
+	}
+}
+
+func TestPluginRunInsideAServiceGetsItsManifest(t *testing.T) {
+
+	dir := mustGenerate(t, "svc", "--type", "payment")
+
+	plugins := t.TempDir()
+	writePlugin(t, plugins, "audit")
+
+	cmd := exec.Command(binary, "audit")
+	cmd.Dir = filepath.Join(dir, "app", "router")
+	cmd.Env = pluginEnv(plugins)
+
+	out, _ := cmd.CombinedOutput()
+
+	if !strings.Contains(string(out), "manifest="+filepath.Join(dir, ".gomicrogen.json")) {
+		t.Errorf("the plugin must receive the service manifest:\n%s", out)
+	}
+}
+
+func TestBuiltinCommandsWinOverPlugins(t *testing.T) {
+
+	plugins := t.TempDir()
+	writePlugin(t, plugins, "types")
+
+	cmd := exec.Command(binary, "types")
+	cmd.Dir = repoRoot
+	cmd.Env = pluginEnv(plugins)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil || strings.Contains(string(out), "args=") {
+		t.Fatalf("the built-in types command must run, got %v:\n%s", err, out)
+	}
+}
+
+func TestUnknownSubcommandWithoutPluginFails(t *testing.T) {
+
+	cmd := exec.Command(binary, "no-such-command")
+	cmd.Dir = repoRoot
+	cmd.Env = pluginEnv(t.TempDir())
+
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("an unknown command must fail:\n%s", out)
+	}
+	if !strings.Contains(string(out), "unknown command") {
+		t.Errorf("expected cobra's unknown command error, got:\n%s", out)
+	}
+}
+
+func TestPluginListShowsDiscoveredPlugins(t *testing.T) {
+
+	plugins := t.TempDir()
+	writePlugin(t, plugins, "psp-onboard")
+	writePlugin(t, plugins, "new")
+
+	cmd := exec.Command(binary, "plugin", "list")
+	cmd.Dir = repoRoot
+	cmd.Env = pluginEnv(plugins)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("plugin list failed: %v\n%s", err, out)
+	}
+
+	for _, want := range []string{"psp-onboard", filepath.Join(plugins, "gomicrogen-psp-onboard"), "shadowed by the built-in command"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("plugin list missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestGeneratedServiceCarriesManifest(t *testing.T) {
+
+	dir := mustGenerate(t, "svc", "--type", "casino", "--db-driver", "postgres")
+
+	for _, want := range []string{`"service_name": "svc"`, `"module_name": "github.com/test-org/svc"`, `"type": "casino"`, `"database_driver": "postgres"`} {
+		if !fileContains(t, dir, ".gomicrogen.json", want) {
+			t.Errorf(".gomicrogen.json missing %s", want)
+		}
+	}
+}
+
+func TestHooksNeedingGoModAreSkippedWithoutIt(t *testing.T) {
+
+	_, out, err := generate(t, "svc", "--type", "payment")
+	if err != nil {
+		t.Fatalf("generation failed: %v\n%s", err, out)
+	}
+
+	if !strings.Contains(out, "swagger docs: skipped (needs go-mod)") {
+		t.Errorf("swag must be skipped under --go-mod=false:\n%s", out)
+	}
+}
+
+func TestNoHooksSkipsEveryHook(t *testing.T) {
+
+	_, out, err := generate(t, "svc", "--type", "payment", "--no-hooks")
+	if err != nil {
+		t.Fatalf("generation failed: %v\n%s", err, out)
+	}
+
+	for _, hook := range []string{"swagger docs"} {
+		if strings.Contains(out, hook+":") {
+			t.Errorf("--no-hooks must not report %s:\n%s", hook, out)
+		}
+	}
+}
+
+// hookedTemplates copies the real base into a fresh templates tree with one
+// type whose type.json declares the given hooks, and returns the directory to
+// run gomicrogen from.
+func hookedTemplates(t *testing.T, hooks string) string {
+	t.Helper()
+
+	cwd := t.TempDir()
+	templates := filepath.Join(cwd, "templates")
+
+	if err := os.CopyFS(filepath.Join(templates, "base"), os.DirFS(filepath.Join(repoRoot, "templates", "base"))); err != nil {
+		t.Fatalf("copy base: %v", err)
+	}
+	if err := os.CopyFS(filepath.Join(templates, "types", "hooked"), os.DirFS(filepath.Join(repoRoot, "templates", "types", "general"))); err != nil {
+		t.Fatalf("copy type: %v", err)
+	}
+
+	manifest := `{"description": "hooked", "hooks": ` + hooks + `}`
+	if err := os.WriteFile(filepath.Join(templates, "types", "hooked", "type.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write type.json: %v", err)
+	}
+
+	return cwd
+}
+
+func TestRequiredHookFailureFailsGeneration(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook is a shell command")
+	}
+
+	cwd := hookedTemplates(t, `[
+		{"name": "marker", "command": "sh", "args": ["-c", "echo {{ .ServiceName }} > hooked.txt"]},
+		{"name": "broken", "command": "sh", "args": ["-c", "echo boom; exit 3"]},
+		{"name": "never", "command": "sh", "args": ["-c", "touch never.txt"]}
+	]`)
+
+	out := t.TempDir()
+
+	cmd := exec.Command(binary, "new", "svc", "--module", "github.com/test-org/svc",
+		"--type", "hooked", "--output-dir", out, "--git=false", "--go-mod=false")
+	cmd.Dir = cwd
+
+	combined, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("a failing required hook must fail generation:\n%s", combined)
+	}
+
+	for _, want := range []string{"marker: succeeded", "broken: failed", "boom", "--no-hooks"} {
+		if !strings.Contains(string(combined), want) {
+			t.Errorf("output missing %q:\n%s", want, combined)
+		}
+	}
+
+	dir := filepath.Join(out, "svc")
+
+	if !fileContains(t, dir, "hooked.txt", "svc") {
+		t.Error("hooks must run in the service root with rendered arguments")
+	}
+	if exists(t, dir, "never.txt") {
+		t.Error("hooks after a failed required hook must not run")
+	}
+}
+
+func TestNoHooksBypassesAFailingHook(t *testing.T) {
+
+	cwd := hookedTemplates(t, `[{"command": "sh", "args": ["-c", "exit 3"]}]`)
+
+	cmd := exec.Command(binary, "new", "svc", "--module", "github.com/test-org/svc",
+		"--type", "hooked", "--output-dir", t.TempDir(), "--git=false", "--go-mod=false", "--no-hooks")
+	cmd.Dir = cwd
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("--no-hooks must skip the failing hook: %v\n%s", err, out)
+	}
+}
+
+// fakeProtoc installs a protoc that writes the files the real one would, plus
+// both plugins, and returns an environment that finds only those.
+func fakeProtoc(t *testing.T) []string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tools are shell scripts")
+	}
+
+	bin := t.TempDir()
+
+	protoc := `#!/bin/sh
+for arg in "$@"; do
+	case "$arg" in
+	*.proto) : > "${arg%.proto}.pb.go"; : > "${arg%.proto}_grpc.pb.go" ;;
+	esac
+done
+`
+	for name, script := range map[string]string{"protoc": protoc, "protoc-gen-go": "#!/bin/sh\n", "protoc-gen-go-grpc": "#!/bin/sh\n"} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0o755); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	return protoEnv(t, bin)
+}
+
+// protoEnv limits tool lookup to bin: PATH and everywhere go install puts
+// binaries.
+func protoEnv(t *testing.T, bin string) []string {
+
+	home := t.TempDir()
+
+	return append(os.Environ(), "PATH="+bin, "GOBIN=", "GOPATH="+home, "HOME="+home)
+}
+
+func TestProtoIsCompiledDuringGeneration(t *testing.T) {
+
+	env := fakeProtoc(t)
+
+	for _, tc := range []struct{ typ, proto string }{
+		{"casino", "app/grpc/casino/casino-service"},
+		{"payment", "app/grpc/wallet/wallet-service"},
+	} {
+		out := t.TempDir()
+
+		cmd := exec.Command(binary, "new", "svc", "--module", "github.com/test-org/svc",
+			"--type", tc.typ, "--output-dir", out, "--git=false", "--go-mod=false")
+		cmd.Dir = repoRoot
+		cmd.Env = env
+
+		combined, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: generation failed: %v\n%s", tc.typ, err, combined)
+		}
+
+		dir := filepath.Join(out, "svc")
+
+		for _, generated := range []string{tc.proto + ".pb.go", tc.proto + "_grpc.pb.go"} {
+			if !exists(t, dir, generated) {
+				t.Errorf("%s: %s was not generated:\n%s", tc.typ, generated, combined)
+			}
+		}
+	}
+}
+
+func TestMissingProtoToolsOnlyWarn(t *testing.T) {
+
+	out := t.TempDir()
+
+	cmd := exec.Command(binary, "new", "svc", "--module", "github.com/test-org/svc",
+		"--type", "payment", "--output-dir", out, "--git=false", "--go-mod=false")
+	cmd.Dir = repoRoot
+	cmd.Env = protoEnv(t, t.TempDir())
+
+	combined, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("missing protoc must not fail generation: %v\n%s", err, combined)
+	}
+
+	for _, want := range []string{"Skipping protobuf compilation", "protoc-gen-go-grpc", "make proto"} {
+		if !strings.Contains(string(combined), want) {
+			t.Errorf("output missing %q:\n%s", want, combined)
+		}
+	}
+
+	if exists(t, filepath.Join(out, "svc"), "app/grpc/wallet/wallet-service.pb.go") {
+		t.Error("nothing can be compiled without the tools")
+	}
+}
+
+func TestGRPCHandlersAreStubbedAndRegistered(t *testing.T) {
+
+	cases := []struct {
+		serviceType, handler, register string
+	}{
+		{"casino", "app/grpc/casino/casino_handler.go", "casino.RegisterCasinoServer(s, &casino.CasinoHandler{"},
+		{"payment", "app/grpc/wallet/wallet_handler.go", "wallet.RegisterWalletServer(s, &wallet.WalletHandler{"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.serviceType, func(t *testing.T) {
+
+			dir := mustGenerate(t, "svc", "--type", tc.serviceType)
+
+			for _, rel := range []string{tc.handler, strings.TrimSuffix(tc.handler, ".go") + "_test.go"} {
+				if !exists(t, dir, rel) {
+					t.Errorf("missing %s", rel)
+				}
+			}
+
+			if !fileContains(t, dir, tc.handler, "apperr.Unimplemented(") {
+				t.Error("the stubs must answer with apperr, as the HTTP handlers do")
+			}
+
+			if !fileContains(t, dir, "app/router/grpc.go", tc.register) {
+				t.Error("app/router/grpc.go must register the handler")
+			}
+			if !fileContains(t, dir, "app/router/router.go", "a.registerGRPCServers(s)") {
+				t.Error("GRPCRun must register the handlers before serving")
+			}
+		})
+	}
+
+	if dir := mustGenerate(t, "svc", "--type", "general"); exists(t, dir, "app/router/grpc.go") {
+		t.Error("a service without protos must not get gRPC registration")
+	}
+}
+
+func TestCasinoHandlerStubsEveryRPC(t *testing.T) {
+
+	dir := mustGenerate(t, "svc", "--type", "casino")
+
+	for _, rpc := range []string{"Debit", "Credit", "Cancel", "Settlement"} {
+
+		if !fileContains(t, dir, "app/grpc/casino/casino_handler.go", "func (h *CasinoHandler) "+rpc+"(ctx context.Context") {
+			t.Errorf("no stub for Casino.%s", rpc)
+		}
+		if !fileContains(t, dir, "app/grpc/casino/casino_handler_test.go", `{"`+rpc+`", func() error`) {
+			t.Errorf("no test row for Casino.%s", rpc)
+		}
+	}
+}
+
+func TestMakefileHasProtoTarget(t *testing.T) {
+
+	dir := mustGenerate(t, "svc", "--type", "payment")
+
+	for _, want := range []string{"proto:", "buf generate", "--go-grpc_opt=paths=source_relative"} {
+		if !fileContains(t, dir, "Makefile", want) {
+			t.Errorf("Makefile missing %q", want)
+		}
+	}
+
+	for _, rel := range []string{"buf.yaml", "buf.gen.yaml"} {
+		if !exists(t, dir, rel) {
+			t.Errorf("%s is missing", rel)
+		}
+	}
+}
+
+func TestGeneratedServicesShutDownGracefully(t *testing.T) {
+
+	cases := map[string][]string{
+		"general": {`a.onDrain("http", a.E.Shutdown)`, `a.onClose("database", a.DB.Close)`, `a.onClose("redis", a.RedisConn.Close)`},
+		"casino":  {`a.onDrain("grpc", func(ctx context.Context) error { return stopGRPC(ctx, s) })`, "s.GracefulStop()"},
+		"payment": {`a.onDrain("consumers", q.Shutdown)`, `a.onClose("rabbitmq", broker.Close)`},
+	}
+
+	for serviceType, wants := range cases {
+		t.Run(serviceType, func(t *testing.T) {
+
+			dir := mustGenerate(t, "svc", "--type", serviceType)
+
+			for _, want := range wants {
+				if !fileContains(t, dir, "app/router/router.go", want) {
+					t.Errorf("router.go missing %q", want)
+				}
+			}
+
+			if !fileContains(t, dir, "main.go", "signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)") || !fileContains(t, dir, "main.go", "a.Run(signalCtx)") {
+				t.Error("main.go must cancel Run on SIGTERM")
+			}
+			if !exists(t, dir, "app/router/shutdown.go") || !exists(t, dir, "app/router/shutdown_test.go") {
+				t.Error("the shutdown coordinator and its test are missing")
+			}
+			if !fileContains(t, dir, "docker-compose-local.yml", "SHUTDOWN_TIMEOUT: 25") {
+				t.Error("docker-compose-local.yml must set SHUTDOWN_TIMEOUT")
+			}
+		})
+	}
+
+	if dir := mustGenerate(t, "svc", "--type", "payment"); fileContains(t, dir, "app/queue/queue.go", "select {}") {
+		t.Error("InitQueues must not block forever")
+	}
+}
+
+// Generated services read their environment in app/config only, and the
+// RabbitMQ settings exist only where the type declares the queue feature.
+func TestGeneratedServicesLoadTypedConfig(t *testing.T) {
+
+	for _, serviceType := range []string{"general", "casino", "payment"} {
+		t.Run(serviceType, func(t *testing.T) {
+
+			dir := mustGenerate(t, "svc", "--type", serviceType)
+
+			for _, rel := range []string{"app/config/config.go", "app/config/loader.go", "app/config/config_test.go"} {
+				if !exists(t, dir, rel) {
+					t.Errorf("%s is missing", rel)
+				}
+			}
+
+			if !fileContains(t, dir, "main.go", `flag.Bool("print-config"`) || !fileContains(t, dir, "main.go", "a.Initialize(tracer, ctx, cfg, dbInstance)") {
+				t.Error("main.go must load the config, offer --print-config and inject it")
+			}
+
+			err := filepath.WalkDir(filepath.Join(dir, "app"), func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.Contains(path, filepath.Join("app", "config")) {
+					return err
+				}
+
+				body, err := os.ReadFile(path)
+				if err == nil && strings.Contains(string(body), "os.Getenv(") {
+					t.Errorf("%s reads the environment outside app/config", path)
+				}
+
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hasRabbit := fileContains(t, dir, "app/config/config.go", "func (r RabbitMQ) URI() string")
+			if hasRabbit != (serviceType == "payment") {
+				t.Errorf("config.RabbitMQ present = %v, want it only for queue services", hasRabbit)
+			}
+
+			if !fileContains(t, dir, "docker-compose-local.yml", "SESSION_SECRET:") {
+				t.Error("docker-compose-local.yml must set the required SESSION_SECRET")
+			}
+		})
+	}
+}
+
+// .env.example, ENVIRONMENT.md and the compose environment come from the same
+// env.json, so they must list the same variables, and every variable
+// app/config reads must be among them.
+func TestGeneratedEnvFilesAgree(t *testing.T) {
+
+	configRead := regexp.MustCompile(`\bl\.[a-z]+[A-Za-z]*\("([A-Z_]+)"`)
+	composeEntry := regexp.MustCompile(`(?m)^      ([A-Z][A-Z0-9_]*):`)
+
+	cases := []struct {
+		serviceType string
+		args        []string
+		want        []string
+		not         []string
+	}{
+		{"general", nil, []string{"SESSION_SECRET", "GLOBAL_REDIS_HOST"}, []string{"RABBITMQ_HOST", "SYSTEM_GRPC_PORT", "DATABASE_SSL_MODE"}},
+		{"casino", []string{"--db-driver", "postgres"}, []string{"SYSTEM_GRPC_PORT", "DATABASE_SSL_MODE"}, []string{"QUEUES"}},
+		{"payment", nil, []string{"RABBITMQ_HOST", "QUEUES", "<queue>_workers"}, []string{"DATABASE_SSL_MODE"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.serviceType, func(t *testing.T) {
+
+			dir := mustGenerate(t, "svc", append([]string{"--type", tc.serviceType}, tc.args...)...)
+
+			read := func(rel string) string {
+				body, err := os.ReadFile(filepath.Join(dir, rel))
+				if err != nil {
+					t.Fatalf("read %s: %v", rel, err)
+				}
+				return string(body)
+			}
+
+			example, reference, compose := read(".env.example"), read("ENVIRONMENT.md"), read("docker-compose-local.yml")
+
+			listed := func(key string) bool {
+				return strings.Contains(example, "\n"+key+"=") || strings.Contains(example, "\n# "+key+"=")
+			}
+
+			for _, key := range tc.want {
+				if !listed(key) || !strings.Contains(reference, "`"+key+"`") {
+					t.Errorf("%s is missing from .env.example or ENVIRONMENT.md", key)
+				}
+			}
+			for _, key := range tc.not {
+				if listed(key) || strings.Contains(reference, "`"+key+"`") || strings.Contains(compose, " "+key+":") {
+					t.Errorf("%s does not apply to this service", key)
+				}
+			}
+
+			for _, m := range composeEntry.FindAllStringSubmatch(compose, -1) {
+				if !listed(m[1]) || !strings.Contains(reference, "`"+m[1]+"`") {
+					t.Errorf("compose sets %s, which .env.example and ENVIRONMENT.md do not list", m[1])
+				}
+			}
+
+			for _, m := range configRead.FindAllStringSubmatch(read("app/config/config.go"), -1) {
+				if !strings.Contains(reference, "`"+m[1]+"`") {
+					t.Errorf("app/config reads %s, which ENVIRONMENT.md does not document", m[1])
+				}
+			}
+
+			if strings.Contains(example, "SESSION_SECRET=local-session-secret") || !strings.Contains(compose, "SESSION_SECRET: local-session-secret") {
+				t.Error("secrets belong in the compose file only, never in .env.example")
+			}
+			if exists(t, dir, "env.json") {
+				t.Error("env.json is template metadata and must not be emitted")
+			}
+		})
+	}
+}
+
+func TestTemplatesLint(t *testing.T) {
+
+	cmd := exec.Command(binary, "templates", "lint")
+	cmd.Dir = repoRoot
+
+	if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "✅") {
+		t.Fatalf("the shipped templates must lint clean: %v\n%s", err, out)
+	}
+
+	templates := filepath.Join(t.TempDir(), "templates")
+	if err := os.CopyFS(templates, os.DirFS(filepath.Join(repoRoot, "templates"))); err != nil {
+		t.Fatalf("copy templates: %v", err)
+	}
+
+	router := filepath.Join(templates, "types", "payment", "app", "router", "router.go.tmpl")
+	body, err := os.ReadFile(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body = []byte(strings.Replace(string(body), "func (a *App) GRPCRun() {\n", "func (a *App) GRPCRun() {\n\n\tlog.Println(os.Getenv(\"PSP_CALLBACK_URL\"))\n", 1))
+	if err := os.WriteFile(router, body, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(binary, "templates", "lint", templates).CombinedOutput()
+	if err == nil {
+		t.Fatalf("an undeclared variable must fail the lint:\n%s", out)
+	}
+
+	if !strings.Contains(string(out), "types/payment/app/router/router.go.tmpl:") || !strings.Contains(string(out), "PSP_CALLBACK_URL is read but not declared") {
+		t.Errorf("the finding must name the file, line and key:\n%s", out)
+	}
+}
+
+// addClient runs `gomicrogen add client` from inside dir.
+func addClient(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+
+	cmd := exec.Command(binary, append([]string{"add", "client", "--proto=false", "--go-mod=false"}, args...)...)
+	cmd.Dir = dir
+
+	out, err := cmd.CombinedOutput()
+
+	return string(out), err
+}
+
+func TestAddClientWiresAnotherService(t *testing.T) {
+
+	payment := mustGenerate(t, "payment-service", "--type", "payment", "--grpc-port", "9091")
+	dir := mustGenerate(t, "svc", "--type", "general")
+
+	out, err := addClient(t, filepath.Join(dir, "app"), payment)
+	if err != nil {
+		t.Fatalf("add client failed: %v\n%s", err, out)
+	}
+
+	checks := []struct{ rel, want string }{
+		{"app/grpc/wallet/wallet-service.proto", `option go_package = "github.com/test-org/svc/app/grpc/wallet";`},
+		{"app/grpc/wallet/fake_wallet_client.go", "type FakeWalletClient struct"},
+		{"app/router/router.go", "WalletClient    wallet.WalletClient"},
+		{"app/router/router.go", "walletConn := getGrpcConn(cfg.Upstreams.WalletEndpoint)"},
+		{"app/router/router.go", `a.onClose("wallet grpc", walletConn.Close)`},
+		{"app/config/upstreams.go", `WalletEndpoint: l.required("WALLET_SERVICE_ENDPOINT"),`},
+		{"app/router/router.go", "a.WalletClient = wallet.NewWalletClient(walletConn)"},
+		{"app/router/router.go", `a.Health.Register(health.Check{Name: "wallet-service", Run: health.GRPC(walletConn)})`},
+		{"app/router/grpc_client.go", "func getGrpcConn(target string) *grpc.ClientConn"},
+		{"app/controllers/controller.go", "WalletClient    wallet.WalletClient"},
+		{"docker-compose-local.yml", "WALLET_SERVICE_ENDPOINT: host.docker.internal:9091"},
+	}
+
+	for _, c := range checks {
+		if !fileContains(t, dir, c.rel, c.want) {
+			t.Errorf("%s missing %q", c.rel, c.want)
+		}
+	}
+
+	// grpc_client.go imports otelgrpc, which the module does not require yet
+	if !strings.Contains(out, "Run go mod tidy in "+dir) {
+		t.Errorf("the summary must say to run go mod tidy when it did not:\n%s", out)
+	}
+
+	out, err = addClient(t, dir, payment)
+	if err == nil || !strings.Contains(out, "already wired") {
+		t.Errorf("a second add client must refuse, got %v:\n%s", err, out)
+	}
+}
+
+func TestAddClientReusesTheServicesOwnProto(t *testing.T) {
+
+	dir := mustGenerate(t, "svc", "--type", "payment")
+
+	out, err := addClient(t, dir, filepath.Join(dir, "app", "grpc", "wallet", "wallet-service.proto"), "--endpoint", "wallet-service:81")
+	if err != nil {
+		t.Fatalf("add client failed: %v\n%s", err, out)
+	}
+
+	if !strings.Contains(out, "Using the service's own app/grpc/wallet/wallet-service.proto") {
+		t.Errorf("the existing proto must be reused:\n%s", out)
+	}
+	if !fileContains(t, dir, "docker-compose-local.yml", "WALLET_SERVICE_ENDPOINT: wallet-service:81") {
+		t.Error("--endpoint must reach docker-compose-local.yml")
+	}
+	if exists(t, dir, "app/router/grpc_client.go") {
+		t.Error("a payment service already has getGrpcConn in router.go")
+	}
+}
+
+func TestAddClientOutsideAServiceFails(t *testing.T) {
+
+	cmd := exec.Command(binary, "add", "client", "wallet-service.proto")
+	cmd.Dir = t.TempDir()
+
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "No .gomicrogen.json found") {
+		t.Errorf("add client outside a service must fail, got %v:\n%s", err, out)
+	}
+}
+
 func TestModuleFlagIsRequired(t *testing.T) {
 
 	cmd := exec.Command(binary, "new", "svc", "--output-dir", t.TempDir(), "--git=false", "--go-mod=false")
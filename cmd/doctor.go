@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Choplife-group/gomicrogen/internal/doctor"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the installation and the tools generated services need",
+	Long: `Check the gomicrogen installation and the local toolchain.
+
+Reports the version, the templates directory in use and its layout, whether
+each service type is valid, and which of go, git, docker, swag, protoc and air
+are installed. Missing go or git, a Go older than the one generated services
+require, missing templates or a broken type are failures and make the command
+exit non-zero; missing optional tools are warnings.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		report := doctor.Diagnose(doctor.Options{
+			Version:      fmt.Sprintf("%s-%s (%s)", appVersion, appCommit, appDate),
+			TemplatesDir: findTemplatesDir(),
+		})
+
+		if err := report.Write(cmd.OutOrStdout()); err != nil {
+			return err
+		}
+
+		if failed := report.Failed(); failed > 0 {
+
+			// the table already explains each failure
+			cmd.SilenceUsage = true
+
+			return fmt.Errorf("❌ %d check(s) failed", failed)
+		}
+
+		cmd.Println("\n✅ Ready to generate services")
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -1,14 +1,18 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/Choplife-group/gomicrogen/internal/config"
 	"github.com/Choplife-group/gomicrogen/internal/generator"
+	"github.com/Choplife-group/gomicrogen/internal/manifest"
+	"github.com/Choplife-group/gomicrogen/internal/protobuf"
 	"github.com/spf13/cobra"
 )
 
@@ -32,6 +36,8 @@ var (
 	initGit             bool
 	runGoMod            bool
 	forceOverwrite      bool
+	noHooks             bool
+	compileProto        bool
 )
 
 var newCmd = &cobra.Command{
@@ -139,6 +145,11 @@ Examples:
 			return err
 		}
 
+		typeManifest, err := generator.ReadTypeManifest(overlayDir)
+		if err != nil {
+			return fmt.Errorf("❌ Service type %q is broken: %w", canonicalType, err)
+		}
+
 		// Check if directory already exists
 		if err := checkExistingService(serviceName, targetDir); err != nil {
 			if !forceOverwrite {
@@ -155,6 +166,7 @@ Examples:
 		// Create service configuration
 		serviceConfig := config.NewServiceConfig(serviceName)
 		serviceConfig.Type = canonicalType
+		serviceConfig.Features = typeManifest.Features
 
 		// Override defaults with provided flags
 		if moduleName != "" {
@@ -217,6 +229,26 @@ Examples:
 			return fmt.Errorf("failed to generate service: %w", err)
 		}
 
+		// Record how the service was generated, for commands and plugins run
+		// from inside it later
+		if err := manifest.Write(targetDir, manifest.FromConfig(serviceConfig, appVersion)); err != nil {
+			return err
+		}
+
+		// Stub a handler for every service the overlay's .proto files declare,
+		// registered in app/router/grpc.go
+		if err := writeGRPCSkeletons(targetDir, serviceConfig); err != nil {
+			return fmt.Errorf("failed to generate gRPC handlers: %w", err)
+		}
+
+		// Compile the overlay's protobuf before go mod tidy, so the generated
+		// code's grpc and protobuf imports are accounted for
+		if compileProto {
+			if err := compileProtos(targetDir); err != nil {
+				return fmt.Errorf("failed to compile protobuf: %w\n\n💡 Retry with --force, or pass --proto=false and run make proto yourself", err)
+			}
+		}
+
 		// Initialize Go module if requested
 		if runGoMod {
 			if err := initializeGoModule(targetDir, serviceConfig.ModuleName); err != nil {
@@ -224,6 +256,25 @@ Examples:
 			}
 		}
 
+		// Run the type's post-generation hooks before the initial commit, so
+		// whatever they produce is part of it
+		if !noHooks && len(typeManifest.Hooks) > 0 {
+
+			features := append([]string{serviceConfig.DatabaseDriver}, typeManifest.Features...)
+			if runGoMod {
+				features = append(features, generator.FeatureGoMod)
+			}
+			if initGit {
+				features = append(features, generator.FeatureGit)
+			}
+
+			results, err := generator.RunHooks(context.Background(), targetDir, typeManifest.Hooks, features, serviceConfig, generator.ExecCommand)
+			printHookResults(results)
+			if err != nil {
+				return fmt.Errorf("❌ %w\n\n💡 Fix the problem and retry with --force, or skip hooks with --no-hooks", err)
+			}
+		}
+
 		// Initialize Git repository if requested
 		if initGit {
 			if err := initializeGitRepo(targetDir); err != nil {
@@ -244,6 +295,82 @@ Examples:
 	},
 }
 
+// writeGRPCSkeletons writes the handlers, their tests and their registration
+// for the services in the generated .proto files.
+func writeGRPCSkeletons(targetDir string, cfg *config.ServiceConfig) error {
+
+	files, err := protobuf.Files(targetDir)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	written, err := protobuf.Skeletons(targetDir, cfg.ServiceName, cfg.ModuleName, files)
+	for _, rel := range written {
+		fmt.Printf("Generated: %s\n", filepath.Join(targetDir, filepath.FromSlash(rel)))
+	}
+
+	return err
+}
+
+// compileProtos compiles the service's .proto files with whichever of buf or
+// protoc is installed. Missing tools only warn: the service is still written
+// and make proto finishes the job once they are installed.
+func compileProtos(targetDir string) error {
+
+	files, err := protobuf.Files(targetDir)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	toolchain, err := protobuf.NewLocator().Toolchain(targetDir)
+	if err != nil {
+		fmt.Printf("⚠️  Skipping protobuf compilation: %v\n", err)
+		fmt.Printf("   Install them, then run: make proto (the gRPC handlers build against its output)\n")
+		return nil
+	}
+
+	fmt.Printf("Compiling protobuf with %s...\n", toolchain.Compiler)
+
+	if out, err := protobuf.Compile(context.Background(), targetDir, toolchain, files); err != nil {
+		return fmt.Errorf("%w\n%s", err, strings.TrimSpace(string(out)))
+	}
+
+	for _, file := range files {
+		for _, generated := range protobuf.Generated(file) {
+			fmt.Printf("Generated: %s\n", filepath.Join(targetDir, generated))
+		}
+	}
+
+	return nil
+}
+
+// printHookResults reports one line per hook, plus the captured output of any
+// hook that failed.
+func printHookResults(results []generator.HookResult) {
+
+	for _, r := range results {
+
+		switch r.Status {
+		case generator.HookSucceeded:
+			fmt.Printf("🔧 %s: succeeded (%s)\n", r.Name, r.Duration.Round(time.Millisecond))
+		case generator.HookSkipped:
+			fmt.Printf("⏭️  %s: skipped (%s)\n", r.Name, r.Reason)
+		case generator.HookFailed:
+
+			level := "❌"
+			if r.Optional {
+				level = "⚠️ "
+			}
+
+			fmt.Printf("%s %s: failed (%s)\n", level, r.Name, r.Reason)
+
+			if output := strings.TrimSpace(r.Output); output != "" {
+				fmt.Printf("   %s\n", strings.ReplaceAll(output, "\n", "\n   "))
+			}
+		}
+	}
+}
+
 // checkExistingService checks if a service with the given name already exists
 func checkExistingService(serviceName, targetDir string) error {
 	// Check if directory exists
@@ -320,6 +447,8 @@ func init() {
 	newCmd.Flags().BoolVarP(&initGit, "git", "", true, "Initialize Git repository with dev branch")
 	newCmd.Flags().BoolVarP(&runGoMod, "go-mod", "", true, "Run go mod init and go mod tidy")
 	newCmd.Flags().BoolVarP(&forceOverwrite, "force", "", false, "Force overwrite if service already exists")
+	newCmd.Flags().BoolVarP(&compileProto, "proto", "", true, "Compile the service's .proto files with buf or protoc")
+	newCmd.Flags().BoolVarP(&noHooks, "no-hooks", "", false, "Skip the post-generation hooks declared by the service type")
 }
 
 // typeFlagUsage builds the --type help text. Cobra assembles usage strings at
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Choplife-group/gomicrogen/internal/manifest"
+	"github.com/Choplife-group/gomicrogen/internal/plugin"
+	"github.com/spf13/cobra"
+)
+
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Inspect gomicrogen plugins",
+	Long: `Plugins add subcommands without changing gomicrogen itself.
+
+Any executable named gomicrogen-<name> becomes 'gomicrogen <name>'. Plugins are
+searched for, in order, in:
+  • the directories in GOMICROGEN_PLUGIN_PATH
+  • plugins/ next to the gomicrogen binary
+  • ~/.gomicrogen/plugins
+  • PATH
+
+A plugin receives the remaining arguments, the caller's standard streams, and
+these environment variables:
+  GOMICROGEN_TEMPLATES_DIR  the templates directory gomicrogen would use
+  GOMICROGEN_VERSION        the gomicrogen version
+  GOMICROGEN_MANIFEST       the service's .gomicrogen.json, when run inside a
+                            generated service
+  GOMICROGEN_BIN            the gomicrogen binary, to call back into it
+
+Built-in commands always win over a plugin of the same name.`,
+}
+
+var pluginListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the plugins gomicrogen can run",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		plugins := plugin.NewFinder(execDir()).List()
+
+		if len(plugins) == 0 {
+			cmd.Println("📦 No plugins found.")
+			cmd.Println("\n💡 Add one: put an executable named gomicrogen-<name> on PATH or in")
+			cmd.Println("   ~/.gomicrogen/plugins, then run it with: gomicrogen <name>")
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(tw, "NAME\tPATH\tNOTE")
+
+		for _, p := range plugins {
+
+			note := ""
+
+			switch {
+			case p.ShadowedBy != "":
+				note = "⚠️  shadowed by " + p.ShadowedBy
+			case isBuiltinCommand(p.Name):
+				note = "⚠️  shadowed by the built-in command"
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Name, p.Path, note)
+		}
+
+		return tw.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pluginCmd)
+	pluginCmd.AddCommand(pluginListCmd)
+}
+
+// runPlugin runs `gomicrogen <name> args...` as the plugin gomicrogen-<name>
+// when <name> is not a built-in command. It reports false when there is no
+// such plugin, so cobra goes on to print its usual unknown-command error.
+func runPlugin(args []string) (int, bool) {
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return 0, false
+	}
+
+	if isBuiltinCommand(args[0]) {
+		return 0, false
+	}
+
+	path, err := plugin.NewFinder(execDir()).Find(args[0])
+	if err != nil {
+		return 0, false
+	}
+
+	code, err := plugin.Run(path, args[1:], pluginContext())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to run plugin %s: %v\n", path, err)
+		return 1, true
+	}
+
+	return code, true
+}
+
+// isBuiltinCommand reports whether cobra would dispatch name itself, including
+// the help and completion commands it only adds at execution time.
+func isBuiltinCommand(name string) bool {
+
+	rootCmd.InitDefaultHelpCmd()
+	rootCmd.InitDefaultCompletionCmd()
+
+	cmd, _, err := rootCmd.Find([]string{name})
+
+	return err == nil && cmd != rootCmd
+}
+
+func pluginContext() plugin.Context {
+
+	ctx := plugin.Context{
+		Version: appVersion,
+	}
+
+	if templatesDir := findTemplatesDir(); templatesDir != "" {
+
+		if abs, err := filepath.Abs(templatesDir); err == nil {
+			templatesDir = abs
+		}
+
+		ctx.TemplatesDir = templatesDir
+	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		ctx.Manifest = manifest.Find(cwd)
+	}
+
+	if execPath, err := os.Executable(); err == nil {
+		ctx.Binary = execPath
+	}
+
+	return ctx
+}
+
+// execDir is the directory holding the running binary, or "" if unknown.
+func execDir() string {
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+
+	if resolved, err := filepath.EvalSymlinks(execPath); err == nil {
+		execPath = resolved
+	}
+
+	return filepath.Dir(execPath)
+}
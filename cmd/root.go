@@ -54,12 +54,19 @@ Examples:
     --output-dir /path/to/projects
 
 List the available service types with: gomicrogen types
+List installed plugins with: gomicrogen plugin list
 For detailed help on any command, use: gomicrogen [command] --help`,
 	Version: fmt.Sprintf("%s-%s (%s)", appVersion, appCommit, appDate),
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
+
+	// an unknown subcommand may be a gomicrogen-<name> plugin
+	if code, handled := runPlugin(os.Args[1:]); handled {
+		os.Exit(code)
+	}
+
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/Choplife-group/gomicrogen/internal/update"
+	"github.com/spf13/cobra"
+)
+
+var (
+	updateVersion string
+	updateForce   bool
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update gomicrogen and its templates to a newer release",
+	Long: `Download a release, verify it and replace this binary and the templates/
+directory next to it.
+
+The archive for this platform is checked against the release's checksums.txt
+before anything is touched, and both the binary and the templates are swapped
+in as a pair so a failed update leaves the previous install in place.
+
+GOMICROGEN_BASE_URL overrides where archives are fetched from, exactly as it
+does for the installers.
+
+Examples:
+  # Update to the latest release
+  gomicrogen self-update
+
+  # Install a specific release
+  gomicrogen self-update --version v1.4.0
+
+  # Go back to an older release
+  gomicrogen self-update --version v1.2.0 --force`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		execPath, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate the running binary: %w", err)
+		}
+
+		cmd.SilenceUsage = true
+
+		cmd.Printf("Updating gomicrogen %s...\n", appVersion)
+
+		result, err := update.Run(update.Options{
+			Version:        updateVersion,
+			CurrentVersion: appVersion,
+			BaseURL:        os.Getenv(update.BaseURLEnv),
+			ExecPath:       execPath,
+			OS:             runtime.GOOS,
+			Arch:           runtime.GOARCH,
+			Force:          updateForce,
+		})
+		if err != nil {
+			return fmt.Errorf("❌ Update failed: %w", err)
+		}
+
+		cmd.Printf("📦 Verified %s\n", result.Archive)
+		cmd.Printf("✅ Updated gomicrogen %s → %s\n", result.Previous, result.Version)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(selfUpdateCmd)
+
+	selfUpdateCmd.Flags().StringVar(&updateVersion, "version", "", "Release to install, e.g. v1.4.0 (default: latest)")
+	selfUpdateCmd.Flags().BoolVar(&updateForce, "force", false, "Allow reinstalling the same version or downgrading")
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Choplife-group/gomicrogen/internal/generator"
+	"github.com/spf13/cobra"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Work with the service templates",
+	Long: `Work with the templates gomicrogen generates services from.
+
+The installed templates are used unless a directory is given.`,
+}
+
+var templatesLintCmd = &cobra.Command{
+	Use:   "lint [templates-dir]",
+	Short: "Check that every environment variable the templates read is declared",
+	Long: `Check that every environment variable the templates read is declared.
+
+Each template tree declares its variables in env.json: templates/base/env.json
+for every service, and templates/types/<name>/env.json for variables only that
+type reads. Generation turns them into .env.example, ENVIRONMENT.md and the
+environment: block of docker-compose-local.yml.
+
+lint scans the Go templates for keys read through os.Getenv, os.LookupEnv and
+the app/config loader, and fails on any that no env.json declares.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		templatesDir := findTemplatesDir()
+		if len(args) == 1 {
+			templatesDir = args[0]
+		}
+
+		if templatesDir == "" {
+			return fmt.Errorf("❌ Templates directory not found — reinstall gomicrogen or pass the directory to lint")
+		}
+
+		findings, err := generator.LintEnv(generator.ResolveLayout(templatesDir))
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+
+		cmd.Printf("🔍 Linting %s\n", templatesDir)
+
+		if len(findings) == 0 {
+
+			cmd.Println("✅ Every environment variable the templates read is declared")
+
+			return nil
+		}
+
+		for _, f := range findings {
+			cmd.Printf("   ❌ %s\n", f)
+		}
+
+		// the findings already explain the failure
+		cmd.SilenceUsage = true
+
+		return fmt.Errorf("❌ %d undeclared environment variable(s)\n\n💡 Declare each in templates/base/%s, or in the overlay's own %s when only that type reads it", len(findings), generator.EnvSchemaFile, generator.EnvSchemaFile)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(templatesCmd)
+	templatesCmd.AddCommand(templatesLintCmd)
+}
@@ -0,0 +1,667 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Choplife-group/gomicrogen/internal/manifest"
+	"github.com/Choplife-group/gomicrogen/internal/protobuf"
+)
+
+// DefaultEndpoint is used in docker-compose-local.yml when the upstream's
+// gRPC port is unknown: gomicrogen's default gRPC port on the docker host.
+const DefaultEndpoint = "host.docker.internal:8081"
+
+// ErrAlreadyWired means the service already has a client for the upstream.
+var ErrAlreadyWired = errors.New("client is already wired")
+
+// Options describe one `gomicrogen add client`.
+type Options struct {
+	// ServiceDir is the root of the generated service being wired.
+	ServiceDir string
+	ModuleName string
+
+	// Source is a .proto file, or another generated service: its directory or
+	// its .gomicrogen.json.
+	Source string
+
+	// Service picks the proto service when the source declares several.
+	Service string
+
+	// Endpoint overrides the address written to docker-compose-local.yml.
+	Endpoint string
+}
+
+// Result reports what Add did.
+type Result struct {
+	Service  string
+	Proto    string
+	Vendored bool
+	Field    string
+	EnvVar   string
+	Endpoint string
+
+	// Upstream is the config.Upstreams field EnvVar is read into.
+	Upstream string
+
+	// Files lists every file written or edited, relative to ServiceDir.
+	Files []string
+}
+
+// source is a proto to take a service from, and where it came from.
+type source struct {
+	path     string
+	services []protobuf.Service
+	endpoint string
+}
+
+// Add vendors the upstream's proto into app/grpc/<name>, writes a fake client
+// for tests, adds a <Name>Client field to App and controllers.Controller, reads
+// <NAME>_SERVICE_ENDPOINT into config.Upstreams, dials it once in Initialize
+// through getGrpcConn, and adds that variable to docker-compose-local.yml.
+// Compiling the vendored proto is left to the caller.
+func Add(opts Options) (Result, error) {
+
+	var res Result
+
+	sources, err := resolveSource(opts.Source)
+	if err != nil {
+		return res, err
+	}
+
+	src, svc, err := pickService(sources, opts.Service)
+	if err != nil {
+		return res, err
+	}
+
+	name := protobuf.SnakeCase(svc.Name)
+
+	res.Service = svc.Name
+	res.Field = svc.Name + "Client"
+	res.Upstream = svc.Name + "Endpoint"
+	res.EnvVar = strings.ToUpper(strings.TrimSuffix(name, "_service")) + "_SERVICE_ENDPOINT"
+	res.Endpoint = firstNonEmpty(opts.Endpoint, src.endpoint, DefaultEndpoint)
+
+	importPath := opts.ModuleName + "/app/grpc/" + name
+
+	// the router is checked first so a second run fails before touching anything
+	router, app, err := findType(filepath.Join(opts.ServiceDir, "app", "router"), "App")
+	if err != nil {
+		return res, err
+	}
+	if hasField(app, res.Field) {
+		return res, fmt.Errorf("%w: App already has %s", ErrAlreadyWired, res.Field)
+	}
+
+	upstreams, _, err := findType(filepath.Join(opts.ServiceDir, "app", "config"), "Upstreams")
+	if err != nil {
+		return res, err
+	}
+
+	res.Proto, res.Vendored, err = vendor(opts.ServiceDir, src, svc.Name, name, importPath)
+	if err != nil {
+		return res, err
+	}
+	if res.Vendored {
+		res.Files = append(res.Files, res.Proto)
+	}
+
+	vendored, err := readProto(filepath.Join(opts.ServiceDir, filepath.FromSlash(res.Proto)))
+	if err != nil {
+		return res, err
+	}
+
+	fake, err := protobuf.FakeClient(opts.ServiceDir, res.Proto, vendored, svc)
+	if err != nil {
+		return res, err
+	}
+	res.Files = append(res.Files, fake)
+
+	// a proto the service already had keeps its own go_package
+	if vendored.GoImportPath() != "" {
+		importPath = vendored.GoImportPath()
+	}
+
+	pkg := vendored.GoPackageName()
+	typ := pkg + "." + svc.Name + "Client"
+
+	edited, err := wireConfig(opts.ServiceDir, upstreams, res)
+	if err != nil {
+		return res, err
+	}
+	res.Files = append(res.Files, edited...)
+
+	edited, err = wireRouter(opts.ServiceDir, opts.ModuleName, router, res, importPath, pkg)
+	if err != nil {
+		return res, err
+	}
+	res.Files = append(res.Files, edited...)
+
+	edited, err = wireController(opts.ServiceDir, res.Field, typ, importPath)
+	if err != nil {
+		return res, err
+	}
+	res.Files = append(res.Files, edited...)
+
+	if added, err := addComposeEnv(filepath.Join(opts.ServiceDir, "docker-compose-local.yml"), res.EnvVar, res.Endpoint); err != nil {
+		return res, err
+	} else if added {
+		res.Files = append(res.Files, "docker-compose-local.yml")
+	}
+
+	if added, err := addEnvExample(filepath.Join(opts.ServiceDir, ".env.example"), res.Service, res.EnvVar, res.Endpoint); err != nil {
+		return res, err
+	} else if added {
+		res.Files = append(res.Files, ".env.example")
+	}
+
+	return res, nil
+}
+
+// resolveSource reads the protos a source offers. For another generated
+// service that is the protos whose services it registers in app/router/grpc.go,
+// so clients it has vendored itself are not offered, and its gRPC port becomes
+// the default endpoint.
+func resolveSource(src string) ([]source, error) {
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+
+	if !info.IsDir() && filepath.Ext(src) == ".proto" {
+
+		file, err := readProto(src)
+		if err != nil {
+			return nil, err
+		}
+
+		return []source{{path: src, services: file.Services}}, nil
+	}
+
+	manifestPath := src
+	if info.IsDir() {
+		manifestPath = filepath.Join(src, manifest.FileName)
+	}
+
+	m, err := manifest.Read(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a .proto file nor a generated service: %w", src, err)
+	}
+
+	root := filepath.Dir(manifestPath)
+
+	protos, err := protobuf.Files(root)
+	if err != nil {
+		return nil, err
+	}
+
+	registered, _ := os.ReadFile(filepath.Join(root, filepath.FromSlash(protobuf.RegisterFile)))
+
+	var endpoint string
+	if m.GRPCPort != "" {
+		endpoint = "host.docker.internal:" + m.GRPCPort
+	}
+
+	var sources []source
+
+	for _, rel := range protos {
+
+		file, err := readProto(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+
+		var served []protobuf.Service
+		for _, svc := range file.Services {
+			if strings.Contains(string(registered), "Register"+svc.Name+"Server(") {
+				served = append(served, svc)
+			}
+		}
+
+		if len(served) > 0 {
+			sources = append(sources, source{path: filepath.Join(root, filepath.FromSlash(rel)), services: served, endpoint: endpoint})
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("%s (%s) serves no gRPC services", m.ServiceName, root)
+	}
+
+	return sources, nil
+}
+
+func pickService(sources []source, want string) (source, protobuf.Service, error) {
+
+	var names []string
+
+	for _, src := range sources {
+		for _, svc := range src.services {
+
+			if want != "" && strings.EqualFold(svc.Name, want) {
+				return src, svc, nil
+			}
+
+			names = append(names, svc.Name)
+		}
+	}
+
+	switch {
+	case len(names) == 0:
+		return source{}, protobuf.Service{}, errors.New("no gRPC service found")
+	case want != "":
+		return source{}, protobuf.Service{}, fmt.Errorf("no service %q; available: %s", want, strings.Join(names, ", "))
+	case len(names) > 1:
+		return source{}, protobuf.Service{}, fmt.Errorf("several services found, pick one with --service: %s", strings.Join(names, ", "))
+	}
+
+	return sources[0], sources[0].services[0], nil
+}
+
+func readProto(path string) (protobuf.File, error) {
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return protobuf.File{}, err
+	}
+
+	file, err := protobuf.Parse(string(src))
+	if err != nil {
+		return file, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+var goPackageOption = regexp.MustCompile(`(?m)^[ \t]*option[ \t]+go_package[ \t]*=[ \t]*"[^"]*"[ \t]*;`)
+
+var packageStatement = regexp.MustCompile(`(?m)^[ \t]*package[ \t]+[\w.]+[ \t]*;`)
+
+// vendor copies the proto into app/grpc/<name>/ with go_package pointing at
+// that directory. A service that already carries a proto declaring the same
+// service, as a payment service does for Wallet, keeps its own copy.
+func vendor(serviceDir string, src source, service, name, importPath string) (string, bool, error) {
+
+	existing, err := protobuf.Files(serviceDir)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, rel := range existing {
+
+		file, err := readProto(filepath.Join(serviceDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", false, err
+		}
+
+		for _, svc := range file.Services {
+			if svc.Name == service {
+				return rel, false, nil
+			}
+		}
+	}
+
+	body, err := os.ReadFile(src.path)
+	if err != nil {
+		return "", false, err
+	}
+
+	option := fmt.Sprintf("option go_package = %q;", importPath)
+
+	text := string(body)
+
+	switch {
+	case goPackageOption.MatchString(text):
+		text = goPackageOption.ReplaceAllLiteralString(text, option)
+	case packageStatement.MatchString(text):
+		loc := packageStatement.FindStringIndex(text)
+		text = text[:loc[1]] + "\n" + option + text[loc[1]:]
+	default:
+		return "", false, fmt.Errorf("%s has no package statement", src.path)
+	}
+
+	rel := path.Join("app", "grpc", name, filepath.Base(src.path))
+	target := filepath.Join(serviceDir, filepath.FromSlash(rel))
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", false, err
+	}
+
+	if err := os.WriteFile(target, []byte(text), 0o644); err != nil {
+		return "", false, err
+	}
+
+	return rel, true, nil
+}
+
+// wireRouter adds the field to App and dials the client in Initialize, at the
+// endpoint config.Upstreams holds, just before the controller is built, then
+// hands it to the controller. The connection is closed on shutdown. A service
+// with a health registry also gets the upstream as a non-critical check.
+func wireRouter(serviceDir, module string, router *goFile, res Result, importPath, pkg string) ([]string, error) {
+
+	routerDir := filepath.Join(serviceDir, "app", "router")
+
+	initialize := router.method("Initialize")
+	if initialize == nil {
+		return nil, fmt.Errorf("%s has no Initialize method to dial %s in", router.path, res.Service)
+	}
+
+	stmt, lit := compositeLit(initialize.Body, "controllers", "Controller")
+	if stmt == nil {
+		return nil, fmt.Errorf("Initialize in %s never builds a controllers.Controller", router.path)
+	}
+
+	cfg := configParam(initialize)
+	if cfg == "" {
+		return nil, fmt.Errorf("Initialize in %s is not handed the *config.Config to read %s from", router.path, res.EnvVar)
+	}
+
+	endpoint := cfg + ".Upstreams." + res.Upstream
+
+	app := router.structType("App")
+	checked := hasField(app, "Health")
+
+	imports := []string{importPath}
+	if checked {
+		imports = append(imports, module+"/app/health")
+	}
+
+	for _, imp := range imports {
+		if err := router.addImport(imp); err != nil {
+			return nil, err
+		}
+	}
+
+	router.addField(app, res.Field, pkg+"."+res.Service+"Client")
+
+	conn := strings.ToLower(res.Service[:1]) + res.Service[1:] + "Conn"
+
+	// the connection is closed with the database and Redis, once the servers
+	// have drained and nothing calls the upstream any more
+	dial := fmt.Sprintf("\t// %s is dialled once here and shared; %s names it\n", res.Service, res.EnvVar)
+	dial += fmt.Sprintf("\t%s := getGrpcConn(%s)\n\ta.onClose(%q, %s.Close)\n\ta.%s = %s.New%sClient(%s)\n",
+		conn, endpoint, strings.ToLower(res.Service)+" grpc", conn, res.Field, pkg, res.Service, conn)
+
+	if checked {
+
+		// an upstream outage degrades this service rather than taking it out
+		// of rotation, so the check is reported but not critical
+		check := strings.ReplaceAll(strings.ToLower(strings.TrimSuffix(res.EnvVar, "_ENDPOINT")), "_", "-")
+
+		dial += fmt.Sprintf("\ta.Health.Register(health.Check{Name: %q, Run: health.GRPC(%s)})\n", check, conn)
+	}
+
+	dial += "\n"
+
+	router.insert(router.lineStart(stmt.Pos()), dial)
+
+	router.insert(router.lineStart(lit.Rbrace), fmt.Sprintf("\t%s: a.%s,\n", res.Field, res.Field))
+
+	if err := router.save(); err != nil {
+		return nil, err
+	}
+
+	edited := []string{rel(serviceDir, router.path)}
+
+	// typed services have getGrpcConn in router.go; a general service does not
+	has, err := declaresFunc(routerDir, "getGrpcConn")
+	if err != nil {
+		return nil, err
+	}
+
+	if !has {
+
+		if err := os.WriteFile(filepath.Join(routerDir, "grpc_client.go"), []byte(grpcClientSource), 0o644); err != nil {
+			return nil, err
+		}
+
+		edited = append(edited, "app/router/grpc_client.go")
+	}
+
+	return edited, nil
+}
+
+// wireConfig adds the endpoint to config.Upstreams, read as a required
+// variable so a service missing it fails at startup and not on its first call.
+// The smallest environment config_test.go loads gets it too.
+func wireConfig(serviceDir string, upstreams *goFile, res Result) ([]string, error) {
+
+	st := upstreams.structType("Upstreams")
+	if hasField(st, res.Upstream) {
+		return nil, nil
+	}
+
+	read := upstreams.method("upstreams")
+	if read == nil {
+		return nil, fmt.Errorf("%s has no upstreams method to read %s in", upstreams.path, res.EnvVar)
+	}
+
+	_, lit := compositeLit(read.Body, "", "Upstreams")
+	if lit == nil {
+		return nil, fmt.Errorf("upstreams in %s never builds an Upstreams", upstreams.path)
+	}
+
+	upstreams.addField(st, res.Upstream, "string")
+	upstreams.insert(upstreams.offset(lit.Rbrace), fmt.Sprintf("\n%s: l.required(%q),\n", res.Upstream, res.EnvVar))
+
+	if err := upstreams.save(); err != nil {
+		return nil, err
+	}
+
+	edited := []string{rel(serviceDir, upstreams.path)}
+
+	test, err := parseGoFile(filepath.Join(serviceDir, "app", "config", "config_test.go"))
+	if errors.Is(err, os.ErrNotExist) {
+		return edited, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if required := test.function("required"); required != nil {
+
+		var env *ast.CompositeLit
+
+		ast.Inspect(required.Body, func(n ast.Node) bool {
+
+			if cl, ok := n.(*ast.CompositeLit); ok && env == nil {
+				env = cl
+			}
+
+			return env == nil
+		})
+
+		if env != nil {
+
+			test.insert(test.offset(env.Rbrace), fmt.Sprintf("\n%q: %q,\n", res.EnvVar, res.Endpoint))
+
+			if err := test.save(); err != nil {
+				return nil, err
+			}
+
+			edited = append(edited, rel(serviceDir, test.path))
+		}
+	}
+
+	return edited, nil
+}
+
+// configParam is the name Initialize gives its *config.Config.
+func configParam(fn *ast.FuncDecl) string {
+
+	for _, field := range fn.Type.Params.List {
+
+		star, ok := field.Type.(*ast.StarExpr)
+		if !ok {
+			continue
+		}
+
+		if sel, ok := star.X.(*ast.SelectorExpr); ok && sel.Sel.Name == "Config" && len(field.Names) == 1 {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == "config" {
+				return field.Names[0].Name
+			}
+		}
+	}
+
+	return ""
+}
+
+func wireController(serviceDir, field, typ, importPath string) ([]string, error) {
+
+	controller, st, err := findType(filepath.Join(serviceDir, "app", "controllers"), "Controller")
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasField(st, field) {
+
+		if err := controller.addImport(importPath); err != nil {
+			return nil, err
+		}
+
+		controller.addField(st, field, typ)
+
+		if err := controller.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return []string{rel(serviceDir, controller.path)}, nil
+}
+
+// addComposeEnv appends key to the environment block of the service in a
+// docker compose file. It reports false when the key is already set.
+func addComposeEnv(path, key, value string) (bool, error) {
+
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	lines := strings.Split(string(body), "\n")
+
+	start := -1
+	for i, line := range lines {
+
+		if strings.TrimSpace(line) == key+":" || strings.HasPrefix(strings.TrimSpace(line), key+": ") {
+			return false, nil
+		}
+
+		if start < 0 && strings.TrimSpace(line) == "environment:" {
+			start = i
+		}
+	}
+
+	if start < 0 {
+		return false, fmt.Errorf("%s has no environment block", path)
+	}
+
+	indent := len(lines[start]) - len(strings.TrimLeft(lines[start], " "))
+
+	// the block ends at the first non-blank line indented no deeper than
+	// "environment:"; the new key goes after its last entry
+	last := start
+	for i := start + 1; i < len(lines); i++ {
+
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+
+		if len(lines[i])-len(strings.TrimLeft(lines[i], " ")) <= indent {
+			break
+		}
+
+		last = i
+	}
+
+	entryIndent := strings.Repeat(" ", indent+2)
+	if last > start {
+		entryIndent = lines[last][:len(lines[last])-len(strings.TrimLeft(lines[last], " "))]
+	}
+
+	entry := []string{"", entryIndent + key + ": " + value}
+
+	lines = append(lines[:last+1], append(entry, lines[last+1:]...)...)
+
+	return true, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
+}
+
+// addEnvExample appends key to the service's .env.example, so it lists the
+// same variables as the compose file. It reports false when the key is
+// already there or the service predates .env.example.
+func addEnvExample(path, service, key, value string) (bool, error) {
+
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(strings.TrimLeft(line, "# "), key+"=") {
+			return false, nil
+		}
+	}
+
+	entry := fmt.Sprintf("\n# %s gRPC endpoint, host:port (required)\n%s=%s\n", service, key, value)
+
+	return true, os.WriteFile(path, append(body, entry...), 0o644)
+}
+
+func rel(base, path string) string {
+
+	if r, err := filepath.Rel(base, path); err == nil {
+		return filepath.ToSlash(r)
+	}
+
+	return path
+}
+
+func firstNonEmpty(values ...string) string {
+
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// grpcClientSource is getGrpcConn as the typed services' router.go has it.
+const grpcClientSource = `package router
+
+import (
+	"log"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// getGrpcConn dials another service over gRPC with tracing propagated
+func getGrpcConn(target string) *grpc.ClientConn {
+
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		log.Fatalf("Did not connect to %s... %v", target, err)
+	}
+
+	return conn
+}
+`
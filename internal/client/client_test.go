@@ -0,0 +1,321 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Choplife-group/gomicrogen/internal/manifest"
+)
+
+const routerSource = `package router
+
+import (
+	"database/sql"
+
+	"github.com/test-org/svc/app/config"
+	"github.com/test-org/svc/app/controllers"
+)
+
+// router and DB instance
+type App struct {
+	DB         *sql.DB
+	Controller *controllers.Controller
+}
+
+func (a *App) Initialize(cfg *config.Config) {
+
+	a.DB = nil
+
+	// controller keeps the shared connections
+	a.Controller = &controllers.Controller{
+		DB: a.DB,
+	}
+}
+`
+
+const controllerSource = `package controllers
+
+import (
+	"database/sql"
+)
+
+type Controller struct {
+	DB *sql.DB
+}
+`
+
+const upstreamsSource = `package config
+
+type Upstreams struct {
+}
+
+func (l *loader) upstreams() Upstreams {
+
+	return Upstreams{}
+}
+`
+
+const configTestSource = `package config
+
+func required() map[string]string {
+
+	return map[string]string{
+		"SYSTEM_PORT": "8080",
+	}
+}
+`
+
+const composeSource = `services:
+  svc:
+    build: .
+    environment:
+      ENV: dev
+      PORT: 8080
+    ports:
+      - "8080:8080"
+`
+
+const walletProto = `syntax = "proto3";
+option go_package = "github.com/test-org/payment/app/grpc/wallet";
+package protobuf;
+
+service Wallet {
+  rpc Deposit (DepositRequest) returns (DepositResponse) {}
+}
+
+message DepositRequest {}
+message DepositResponse {}
+`
+
+func write(t *testing.T, dir, rel, body string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write %s: %v", rel, err)
+	}
+
+	return path
+}
+
+func read(t *testing.T, dir, rel string) string {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatalf("read %s: %v", rel, err)
+	}
+
+	return string(body)
+}
+
+// service lays out the parts of a generated service Add edits.
+func service(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	write(t, dir, "app/router/router.go", routerSource)
+	write(t, dir, "app/controllers/controller.go", controllerSource)
+	write(t, dir, "app/config/upstreams.go", upstreamsSource)
+	write(t, dir, "app/config/config_test.go", configTestSource)
+	write(t, dir, "docker-compose-local.yml", composeSource)
+	write(t, dir, ".env.example", "# Service\n# HTTP port (required)\nSYSTEM_PORT=8080\n")
+
+	return dir
+}
+
+func TestAddVendorsAndWiresAProto(t *testing.T) {
+
+	dir := service(t)
+	proto := write(t, t.TempDir(), "wallet-service.proto", walletProto)
+
+	res, err := Add(Options{ServiceDir: dir, ModuleName: "github.com/test-org/svc", Source: proto})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	if res.Service != "Wallet" || res.Field != "WalletClient" || res.EnvVar != "WALLET_SERVICE_ENDPOINT" || res.Endpoint != DefaultEndpoint {
+		t.Errorf("Result = %+v", res)
+	}
+
+	vendored := read(t, dir, "app/grpc/wallet/wallet-service.proto")
+	if !strings.Contains(vendored, `option go_package = "github.com/test-org/svc/app/grpc/wallet";`) || strings.Contains(vendored, "test-org/payment") {
+		t.Errorf("go_package must point into the service:\n%s", vendored)
+	}
+
+	router := read(t, dir, "app/router/router.go")
+	for _, want := range []string{
+		`"github.com/test-org/svc/app/grpc/wallet"`,
+		"WalletClient wallet.WalletClient",
+		"walletConn := getGrpcConn(cfg.Upstreams.WalletEndpoint)",
+		`a.onClose("wallet grpc", walletConn.Close)`,
+		"a.WalletClient = wallet.NewWalletClient(walletConn)",
+		"WalletClient: a.WalletClient,",
+		"// controller keeps the shared connections",
+	} {
+		if !strings.Contains(router, want) {
+			t.Errorf("router.go missing %q:\n%s", want, router)
+		}
+	}
+
+	if strings.Contains(router, "os.Getenv") {
+		t.Errorf("the endpoint must be read through the config:\n%s", router)
+	}
+
+	upstreams := read(t, dir, "app/config/upstreams.go")
+	for _, want := range []string{"WalletEndpoint string", `WalletEndpoint: l.required("WALLET_SERVICE_ENDPOINT"),`} {
+		if !strings.Contains(upstreams, want) {
+			t.Errorf("upstreams.go missing %q:\n%s", want, upstreams)
+		}
+	}
+
+	if env := read(t, dir, "app/config/config_test.go"); !strings.Contains(env, `"WALLET_SERVICE_ENDPOINT": "host.docker.internal:8081",`) {
+		t.Errorf("the smallest environment config_test.go loads must set the endpoint:\n%s", env)
+	}
+
+	if strings.Index(router, "getGrpcConn(") > strings.Index(router, "a.Controller = &controllers.Controller{") {
+		t.Errorf("the client must be dialled before the controller is built:\n%s", router)
+	}
+
+	if !strings.Contains(read(t, dir, "app/router/grpc_client.go"), "func getGrpcConn(target string) *grpc.ClientConn") {
+		t.Error("a router without getGrpcConn must get one")
+	}
+
+	if controller := read(t, dir, "app/controllers/controller.go"); !strings.Contains(controller, "WalletClient wallet.WalletClient") {
+		t.Errorf("controller.go missing the field:\n%s", controller)
+	}
+
+	if compose := read(t, dir, "docker-compose-local.yml"); !strings.Contains(compose, "      PORT: 8080\n\n      WALLET_SERVICE_ENDPOINT: host.docker.internal:8081\n    ports:") {
+		t.Errorf("the variable must close the environment block:\n%s", compose)
+	}
+
+	if example := read(t, dir, ".env.example"); !strings.HasSuffix(example, "SYSTEM_PORT=8080\n\n# Wallet gRPC endpoint, host:port (required)\nWALLET_SERVICE_ENDPOINT=host.docker.internal:8081\n") {
+		t.Errorf(".env.example must list the variable too:\n%s", example)
+	}
+
+	read(t, dir, "app/grpc/wallet/fake_wallet_client.go")
+}
+
+func TestAddRegistersTheUpstreamWithTheHealthRegistry(t *testing.T) {
+
+	dir := service(t)
+	write(t, dir, "app/router/router.go", strings.Replace(routerSource, "\tDB         *sql.DB\n", "\tDB         *sql.DB\n\tHealth     *health.Registry\n", 1))
+
+	proto := write(t, t.TempDir(), "wallet-service.proto", walletProto)
+
+	if _, err := Add(Options{ServiceDir: dir, ModuleName: "github.com/test-org/svc", Source: proto}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	router := read(t, dir, "app/router/router.go")
+	for _, want := range []string{
+		`"github.com/test-org/svc/app/health"`,
+		"walletConn := getGrpcConn(cfg.Upstreams.WalletEndpoint)",
+		`a.onClose("wallet grpc", walletConn.Close)`,
+		"a.WalletClient = wallet.NewWalletClient(walletConn)",
+		`a.Health.Register(health.Check{Name: "wallet-service", Run: health.GRPC(walletConn)})`,
+	} {
+		if !strings.Contains(router, want) {
+			t.Errorf("router.go missing %q:\n%s", want, router)
+		}
+	}
+
+	if strings.Contains(router, "Critical") {
+		t.Errorf("an upstream must not be a critical dependency:\n%s", router)
+	}
+}
+
+func TestAddTwiceIsAlreadyWired(t *testing.T) {
+
+	dir := service(t)
+	proto := write(t, t.TempDir(), "wallet-service.proto", walletProto)
+	opts := Options{ServiceDir: dir, ModuleName: "github.com/test-org/svc", Source: proto}
+
+	if _, err := Add(opts); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	before := read(t, dir, "app/router/router.go")
+
+	if _, err := Add(opts); !errors.Is(err, ErrAlreadyWired) {
+		t.Fatalf("second Add = %v, want ErrAlreadyWired", err)
+	}
+
+	if read(t, dir, "app/router/router.go") != before {
+		t.Error("a second Add must not edit the router")
+	}
+}
+
+func TestAddKeepsAProtoTheServiceAlreadyHas(t *testing.T) {
+
+	dir := service(t)
+	write(t, dir, "app/grpc/wallet/wallet-service.proto", strings.Replace(walletProto, "test-org/payment", "test-org/svc", 1))
+	proto := write(t, t.TempDir(), "wallet-service.proto", walletProto)
+
+	res, err := Add(Options{ServiceDir: dir, ModuleName: "github.com/test-org/svc", Source: proto})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	if res.Vendored || res.Proto != "app/grpc/wallet/wallet-service.proto" {
+		t.Errorf("Result = %+v, want the existing proto reused", res)
+	}
+}
+
+func TestAddFromAGeneratedService(t *testing.T) {
+
+	upstream := t.TempDir()
+	write(t, upstream, "app/grpc/wallet/wallet-service.proto", walletProto)
+	write(t, upstream, "app/router/grpc.go", "package router\n\n// wallet.RegisterWalletServer(s, h)\n")
+	// a client the upstream dials itself is not something it serves
+	write(t, upstream, "app/grpc/bonus/bonus-service.proto", "syntax = \"proto3\";\npackage protobuf;\nservice Bonus {}\n")
+
+	if err := manifest.Write(upstream, manifest.Manifest{ServiceName: "payment", ModuleName: "github.com/test-org/payment", GRPCPort: "9091"}); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+
+	res, err := Add(Options{ServiceDir: service(t), ModuleName: "github.com/test-org/svc", Source: upstream})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	if res.Service != "Wallet" || res.Endpoint != "host.docker.internal:9091" {
+		t.Errorf("Result = %+v, want Wallet on the upstream's gRPC port", res)
+	}
+}
+
+func TestPickServiceNeedsAChoiceAmongSeveral(t *testing.T) {
+
+	proto := write(t, t.TempDir(), "two.proto", walletProto+"\nservice Ledger {}\n")
+
+	opts := Options{ServiceDir: service(t), ModuleName: "github.com/test-org/svc", Source: proto}
+
+	if _, err := Add(opts); err == nil || !strings.Contains(err.Error(), "--service: Wallet, Ledger") {
+		t.Fatalf("Add = %v, want a --service hint", err)
+	}
+
+	opts.Service = "ledger"
+	if res, err := Add(opts); err != nil || res.Service != "Ledger" {
+		t.Fatalf("Add with --service = %+v, %v", res, err)
+	}
+}
+
+func TestAddComposeEnvSkipsAKeyAlreadySet(t *testing.T) {
+
+	path := write(t, t.TempDir(), "docker-compose-local.yml", composeSource)
+
+	added, err := addComposeEnv(path, "PORT", "9090")
+	if err != nil || added {
+		t.Fatalf("addComposeEnv = %v, %v, want the existing key kept", added, err)
+	}
+
+	if read(t, filepath.Dir(path), "docker-compose-local.yml") != composeSource {
+		t.Error("the file must be left alone")
+	}
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// goFile is a parsed Go source file and the insertions pending against it.
+// Insertions are made by byte offset and the result is gofmt'd, so the
+// developer's own formatting and comments elsewhere in the file survive.
+type goFile struct {
+	path  string
+	src   []byte
+	fset  *token.FileSet
+	file  *ast.File
+	edits []insertion
+}
+
+type insertion struct {
+	offset int
+	text   string
+}
+
+// findType returns the file in dir declaring the struct type name.
+func findType(dir, name string) (*goFile, *ast.StructType, error) {
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, path := range paths {
+
+		f, err := parseGoFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if st := f.structType(name); st != nil {
+			return f, st, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("no struct %s in %s", name, dir)
+}
+
+func parseGoFile(path string) (*goFile, error) {
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	return &goFile{path: path, src: src, fset: fset, file: file}, nil
+}
+
+func (f *goFile) offset(pos token.Pos) int {
+
+	return f.fset.Position(pos).Offset
+}
+
+// lineStart is the offset of the start of the line holding pos.
+func (f *goFile) lineStart(pos token.Pos) int {
+
+	off := f.offset(pos)
+
+	return bytes.LastIndexByte(f.src[:off], '\n') + 1
+}
+
+func (f *goFile) insert(offset int, text string) {
+
+	f.edits = append(f.edits, insertion{offset: offset, text: text})
+}
+
+func (f *goFile) structType(name string) *ast.StructType {
+
+	for _, decl := range f.file.Decls {
+
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			if ts := spec.(*ast.TypeSpec); ts.Name.Name == name {
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					return st
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasField reports whether st declares field.
+func hasField(st *ast.StructType, field string) bool {
+
+	for _, f := range st.Fields.List {
+		for _, n := range f.Names {
+			if n.Name == field {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// addField appends `name typ` as the last field of st.
+func (f *goFile) addField(st *ast.StructType, name, typ string) {
+
+	f.insert(f.lineStart(st.Fields.Closing), fmt.Sprintf("\t%s %s\n", name, typ))
+}
+
+// hasImport reports whether the file imports path.
+func (f *goFile) hasImport(path string) bool {
+
+	for _, spec := range f.file.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addImport adds path to the file's first parenthesised import block.
+func (f *goFile) addImport(path string) error {
+
+	if f.hasImport(path) {
+		return nil
+	}
+
+	for _, decl := range f.file.Decls {
+
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT || !gen.Rparen.IsValid() {
+			continue
+		}
+
+		f.insert(f.lineStart(gen.Rparen), fmt.Sprintf("\t%q\n", path))
+
+		return nil
+	}
+
+	return fmt.Errorf("%s has no import block to add %s to", f.path, path)
+}
+
+// method returns the body of the method name, whatever its receiver.
+func (f *goFile) method(name string) *ast.FuncDecl {
+
+	for _, decl := range f.file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil && fn.Name.Name == name && fn.Body != nil {
+			return fn
+		}
+	}
+
+	return nil
+}
+
+// function returns the top-level function name.
+func (f *goFile) function(name string) *ast.FuncDecl {
+
+	for _, decl := range f.file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name && fn.Body != nil {
+			return fn
+		}
+	}
+
+	return nil
+}
+
+// declaresFunc reports whether any Go file in dir declares the function name.
+func declaresFunc(dir, name string) (bool, error) {
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return false, err
+	}
+
+	for _, path := range paths {
+
+		f, err := parseGoFile(path)
+		if err != nil {
+			return false, err
+		}
+
+		for _, decl := range f.file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// compositeLit finds the statement in body that builds a `pkg.typ{...}`
+// literal, or a `typ{...}` one when pkg is empty, and the literal itself.
+func compositeLit(body *ast.BlockStmt, pkg, typ string) (ast.Stmt, *ast.CompositeLit) {
+
+	for _, stmt := range body.List {
+
+		var lit *ast.CompositeLit
+
+		ast.Inspect(stmt, func(n ast.Node) bool {
+
+			if lit != nil {
+				return false
+			}
+
+			if cl, ok := n.(*ast.CompositeLit); ok {
+				switch t := cl.Type.(type) {
+				case *ast.SelectorExpr:
+					if id, ok := t.X.(*ast.Ident); ok && id.Name == pkg && t.Sel.Name == typ {
+						lit = cl
+					}
+				case *ast.Ident:
+					if pkg == "" && t.Name == typ {
+						lit = cl
+					}
+				}
+			}
+
+			return true
+		})
+
+		if lit != nil {
+			return stmt, lit
+		}
+	}
+
+	return nil, nil
+}
+
+// save applies the pending insertions, gofmts the result and writes it back.
+func (f *goFile) save() error {
+
+	edits := append([]insertion(nil), f.edits...)
+
+	// apply back to front so earlier offsets stay valid; insertions at the same
+	// offset keep the order they were made in
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].offset > edits[j].offset })
+
+	src := append([]byte(nil), f.src...)
+
+	for i := 0; i < len(edits); {
+
+		j := i
+		var text string
+		for ; j < len(edits) && edits[j].offset == edits[i].offset; j++ {
+			text += edits[j].text
+		}
+
+		off := edits[i].offset
+		src = append(src[:off], append([]byte(text), src[off:]...)...)
+
+		i = j
+	}
+
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("%s no longer parses after editing: %w", f.path, err)
+	}
+
+	return os.WriteFile(f.path, formatted, 0o644)
+}
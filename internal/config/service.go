@@ -1,6 +1,9 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+)
 
 // Supported database drivers. go-utils speaks both dialects: goutils.Db has a
 // Dialect field that switches placeholders, RETURNING and MySQL-only SQL modes.
@@ -39,6 +42,10 @@ func DefaultDatabasePort(driver string) string {
 // IsPostgres lets templates branch on the driver.
 func (c *ServiceConfig) IsPostgres() bool { return c.DatabaseDriver == DriverPostgres }
 
+// Has lets templates branch on a feature the service type declares in its
+// type.json, e.g. {{ if .Has "queue" }}.
+func (c *ServiceConfig) Has(feature string) bool { return slices.Contains(c.Features, feature) }
+
 // ServiceConfig holds the configuration for generating a new microservice
 type ServiceConfig struct {
 	ServiceName         string
@@ -57,6 +64,10 @@ type ServiceConfig struct {
 	RedisDatabaseNumber string
 	RedisPassword       string
 	Environment         string
+
+	// Features are the capabilities the service type declares, such as grpc
+	// and queue
+	Features []string
 }
 
 // NewServiceConfig creates a new ServiceConfig with default values
@@ -68,3 +68,16 @@ func TestIsPostgres(t *testing.T) {
 		t.Error("IsPostgres must report true for the postgres driver")
 	}
 }
+
+func TestHasFeature(t *testing.T) {
+
+	c := NewServiceConfig("svc")
+	if c.Has("queue") {
+		t.Error("a service without features has none")
+	}
+
+	c.Features = []string{"grpc", "queue"}
+	if !c.Has("queue") || c.Has("cron") {
+		t.Errorf("Has with %v is wrong", c.Features)
+	}
+}
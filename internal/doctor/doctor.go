@@ -0,0 +1,248 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Choplife-group/gomicrogen/internal/generator"
+	"github.com/Choplife-group/gomicrogen/internal/version"
+)
+
+// Status is the outcome of a single check.
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+)
+
+func (s Status) String() string {
+
+	switch s {
+	case Pass:
+		return "✅ pass"
+	case Warn:
+		return "⚠️  warn"
+	default:
+		return "❌ fail"
+	}
+}
+
+// Check is one row of the doctor report.
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Report collects checks in the order they ran.
+type Report struct {
+	Checks []Check
+}
+
+func (r *Report) add(name string, status Status, detail string) {
+
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: detail})
+}
+
+// Failed counts the hard failures, which make doctor exit non-zero.
+func (r Report) Failed() int {
+
+	failed := 0
+
+	for _, c := range r.Checks {
+		if c.Status == Fail {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// Write renders the report as an aligned table.
+func (r Report) Write(w io.Writer) error {
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+
+	for _, c := range r.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, c.Status, c.Detail)
+	}
+
+	return tw.Flush()
+}
+
+// Tool is an external program generation or the generated Makefile relies on.
+// Required tools are hard failures: `gomicrogen new` runs them by default.
+type Tool struct {
+	Name     string
+	Args     []string
+	Required bool
+	Hint     string
+}
+
+// Tools are probed in this order.
+var Tools = []Tool{
+	{Name: "go", Args: []string{"env", "GOVERSION"}, Required: true, Hint: "needed for go mod tidy; install from https://go.dev/dl/"},
+	{Name: "git", Args: []string{"--version"}, Required: true, Hint: "needed for --git; pass --git=false to skip"},
+	{Name: "docker", Args: []string{"--version"}, Hint: "needed for make docker-up"},
+	{Name: "swag", Args: []string{"--version"}, Hint: "needed for make swagger: go install github.com/swaggo/swag/cmd/swag@latest"},
+	{Name: "protoc", Args: []string{"--version"}, Hint: "compiles the overlay .proto files when buf is not installed"},
+	{Name: "buf", Args: []string{"--version"}, Hint: "compiles the overlay .proto files with the service's buf.gen.yaml"},
+	{Name: "air", Args: []string{"-v"}, Hint: "needed for make run-air: go install github.com/air-verse/air@latest"},
+}
+
+// Runner runs a tool and returns its combined output. It is swapped out in
+// tests so the report does not depend on what the machine has installed.
+type Runner func(name string, args ...string) (string, error)
+
+// ExecRunner runs tools found on PATH.
+func ExecRunner(name string, args ...string) (string, error) {
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := exec.Command(path, args...).CombinedOutput()
+
+	return string(out), err
+}
+
+// Options is what the doctor inspects.
+type Options struct {
+	Version      string
+	TemplatesDir string
+	Run          Runner
+}
+
+// Diagnose runs every check.
+func Diagnose(opts Options) Report {
+
+	var r Report
+
+	run := opts.Run
+	if run == nil {
+		run = ExecRunner
+	}
+
+	r.add("gomicrogen", Pass, opts.Version)
+
+	goRequired := ""
+
+	if opts.TemplatesDir == "" {
+
+		r.add("templates", Fail, "templates directory not found; reinstall gomicrogen")
+
+	} else {
+
+		layout := generator.ResolveLayout(opts.TemplatesDir)
+
+		abs, err := filepath.Abs(opts.TemplatesDir)
+		if err != nil {
+			abs = opts.TemplatesDir
+		}
+
+		if layout.Legacy {
+			r.add("templates", Warn, abs+" (legacy layout, no type overlays)")
+		} else {
+			r.add("templates", Pass, abs+" (nested layout)")
+		}
+
+		checkTypes(&r, layout)
+
+		goRequired = requiredGoVersion(layout.BaseDir)
+	}
+
+	for _, tool := range Tools {
+		checkTool(&r, tool, run, goRequired)
+	}
+
+	return r
+}
+
+func checkTypes(r *Report, layout generator.Layout) {
+
+	if layout.Legacy {
+		return
+	}
+
+	types := layout.Types()
+	if len(types) == 0 {
+		r.add("types", Warn, "no type overlays found in "+layout.TypesDir)
+		return
+	}
+
+	for _, t := range types {
+
+		if err := layout.ValidateType(t.Name); err != nil {
+			r.add("type "+t.Name, Fail, err.Error())
+			continue
+		}
+
+		r.add("type "+t.Name, Pass, t.Description)
+	}
+}
+
+func checkTool(r *Report, tool Tool, run Runner, goRequired string) {
+
+	missing := Warn
+	if tool.Required {
+		missing = Fail
+	}
+
+	out, err := run(tool.Name, tool.Args...)
+	if err != nil {
+		r.add(tool.Name, missing, "not found: "+tool.Hint)
+		return
+	}
+
+	installed := firstLine(out)
+
+	if tool.Name == "go" && goRequired != "" {
+
+		have := strings.TrimPrefix(installed, "go")
+		if version.Compare(have, goRequired) < 0 {
+			r.add(tool.Name, Fail, fmt.Sprintf("%s is older than go %s in go.mod.tmpl; go mod tidy will fail", installed, goRequired))
+			return
+		}
+	}
+
+	r.add(tool.Name, Pass, installed)
+}
+
+var goDirective = regexp.MustCompile(`(?m)^go\s+(\d+(?:\.\d+)*)\s*$`)
+
+// requiredGoVersion reads the go directive generated services are pinned to.
+func requiredGoVersion(baseDir string) string {
+
+	content, err := os.ReadFile(filepath.Join(baseDir, "go.mod.tmpl"))
+	if err != nil {
+		return ""
+	}
+
+	match := goDirective.FindSubmatch(content)
+	if match == nil {
+		return ""
+	}
+
+	return string(match[1])
+}
+
+func firstLine(s string) string {
+
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+
+	return strings.TrimSpace(s)
+}
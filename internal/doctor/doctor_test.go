@@ -0,0 +1,202 @@
+package doctor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRunner reports the given tools as installed with the given output.
+func fakeRunner(installed map[string]string) Runner {
+
+	return func(name string, args ...string) (string, error) {
+
+		out, ok := installed[name]
+		if !ok {
+			return "", errors.New("executable file not found in $PATH")
+		}
+
+		return out, nil
+	}
+}
+
+// templatesDir builds a nested templates tree pinned to the given go version.
+func templatesDir(t *testing.T, goVersion string, types ...string) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	write := func(rel, body string) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	write("base/go.mod.tmpl", "module {{ .ModuleName }}\n\ngo "+goVersion+"\n")
+
+	for _, name := range types {
+		write(filepath.Join("types", name, "type.json"), `{"description": "`+name+` desc"}`)
+		write(filepath.Join("types", name, "app/router/router.go.tmpl"), "package router")
+	}
+
+	return root
+}
+
+var allTools = map[string]string{
+	"go":     "go1.24.3\n",
+	"git":    "git version 2.43.0\n",
+	"docker": "Docker version 27.0.1, build 7fafd33\n",
+	"swag":   "swag version v1.16.2\n",
+	"protoc": "libprotoc 25.1\n",
+	"buf":    "1.47.2\n",
+	"air":    "\n  __    _   ___\n v1.52.3\n",
+}
+
+func find(t *testing.T, r Report, name string) Check {
+	t.Helper()
+
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	t.Fatalf("no %q check in report", name)
+
+	return Check{}
+}
+
+func TestHealthyEnvironmentPasses(t *testing.T) {
+
+	r := Diagnose(Options{
+		Version:      "1.2.3",
+		TemplatesDir: templatesDir(t, "1.24.0", "general", "payment"),
+		Run:          fakeRunner(allTools),
+	})
+
+	if r.Failed() != 0 {
+		t.Fatalf("Failed() = %d, want 0: %+v", r.Failed(), r.Checks)
+	}
+
+	for _, name := range []string{"gomicrogen", "templates", "type general", "type payment", "go", "git", "docker", "swag", "protoc", "air"} {
+		if c := find(t, r, name); c.Status != Pass {
+			t.Errorf("%s = %v (%s), want pass", name, c.Status, c.Detail)
+		}
+	}
+
+	if c := find(t, r, "templates"); !strings.Contains(c.Detail, "nested") {
+		t.Errorf("templates detail must name the layout, got %q", c.Detail)
+	}
+	if c := find(t, r, "protoc"); c.Detail != "libprotoc 25.1" {
+		t.Errorf("protoc detail = %q, want the first line of its version", c.Detail)
+	}
+}
+
+func TestMissingTemplatesIsAHardFailure(t *testing.T) {
+
+	r := Diagnose(Options{Run: fakeRunner(allTools)})
+
+	if c := find(t, r, "templates"); c.Status != Fail {
+		t.Errorf("templates = %v, want fail", c.Status)
+	}
+	if r.Failed() == 0 {
+		t.Error("doctor must exit non-zero without templates")
+	}
+}
+
+func TestLegacyTemplatesWarn(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go.tmpl"), []byte("package main"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	r := Diagnose(Options{TemplatesDir: root, Run: fakeRunner(allTools)})
+
+	if c := find(t, r, "templates"); c.Status != Warn || !strings.Contains(c.Detail, "legacy") {
+		t.Errorf("templates = %v (%s), want a legacy warning", c.Status, c.Detail)
+	}
+}
+
+func TestInvalidTypeFails(t *testing.T) {
+
+	root := templatesDir(t, "1.24.0", "general")
+
+	if err := os.MkdirAll(filepath.Join(root, "types", "broken"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	r := Diagnose(Options{TemplatesDir: root, Run: fakeRunner(allTools)})
+
+	if c := find(t, r, "type broken"); c.Status != Fail {
+		t.Errorf("a type without a router = %v, want fail", c.Status)
+	}
+}
+
+func TestGoOlderThanTemplatesFails(t *testing.T) {
+
+	tools := map[string]string{"go": "go1.22.5", "git": "git version 2.43.0"}
+
+	r := Diagnose(Options{TemplatesDir: templatesDir(t, "1.24.0", "general"), Run: fakeRunner(tools)})
+
+	c := find(t, r, "go")
+	if c.Status != Fail {
+		t.Fatalf("go = %v, want fail", c.Status)
+	}
+	if !strings.Contains(c.Detail, "1.24.0") {
+		t.Errorf("detail must name the required version, got %q", c.Detail)
+	}
+}
+
+func TestOptionalToolsOnlyWarn(t *testing.T) {
+
+	tools := map[string]string{"go": "go1.24.0", "git": "git version 2.43.0"}
+
+	r := Diagnose(Options{TemplatesDir: templatesDir(t, "1.24.0", "general"), Run: fakeRunner(tools)})
+
+	if r.Failed() != 0 {
+		t.Errorf("optional tools must not fail the run: %+v", r.Checks)
+	}
+
+	for _, name := range []string{"docker", "swag", "protoc", "air"} {
+		if c := find(t, r, name); c.Status != Warn {
+			t.Errorf("%s = %v, want warn", name, c.Status)
+		}
+	}
+}
+
+func TestMissingGitFails(t *testing.T) {
+
+	tools := map[string]string{"go": "go1.24.0"}
+
+	r := Diagnose(Options{TemplatesDir: templatesDir(t, "1.24.0", "general"), Run: fakeRunner(tools)})
+
+	if c := find(t, r, "git"); c.Status != Fail {
+		t.Errorf("git = %v, want fail", c.Status)
+	}
+}
+
+func TestWriteRendersATable(t *testing.T) {
+
+	r := Report{}
+	r.add("go", Pass, "go1.24.0")
+	r.add("protoc", Warn, "not found")
+
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	out := b.String()
+	for _, want := range []string{"CHECK", "STATUS", "go1.24.0", "pass", "warn"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table missing %q:\n%s", want, out)
+		}
+	}
+}
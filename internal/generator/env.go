@@ -0,0 +1,314 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Choplife-group/gomicrogen/internal/config"
+)
+
+// EnvSchemaFile declares the environment variables a template tree reads. The
+// base tree has one; an overlay may add another for variables only its type
+// reads.
+const EnvSchemaFile = "env.json"
+
+// EnvVar is one environment variable a generated service reads.
+type EnvVar struct {
+	Name        string `json:"name"`
+	Group       string `json:"group"`
+	Description string `json:"description"`
+
+	// Default is what the service uses when the variable is unset.
+	Default string `json:"default,omitempty"`
+
+	// Example is written to .env.example and the compose file; Compose
+	// overrides it inside the container. All three are templates rendered with
+	// the service configuration.
+	Example string `json:"example,omitempty"`
+	Compose string `json:"compose,omitempty"`
+
+	Required bool `json:"required,omitempty"`
+
+	// Secret values are never written to .env.example.
+	Secret bool `json:"secret,omitempty"`
+
+	// When names a feature the variable needs, as for hooks: a database driver
+	// or a feature from type.json. Types limits it to some service types.
+	When  string   `json:"when,omitempty"`
+	Types []string `json:"types,omitempty"`
+}
+
+// EnvSchema is the parsed env.json of one or more template trees.
+type EnvSchema struct {
+	Variables []EnvVar `json:"variables"`
+}
+
+// ReadEnvSchema reads the env.json in each directory, in order. A missing
+// file, or an empty directory for base-only generation, contributes nothing.
+func ReadEnvSchema(dirs ...string) (EnvSchema, error) {
+
+	var schema EnvSchema
+
+	seen := map[string]string{}
+
+	for _, dir := range dirs {
+
+		if dir == "" {
+			continue
+		}
+
+		path := filepath.Join(dir, EnvSchemaFile)
+
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return schema, fmt.Errorf("%s is unreadable: %w", path, err)
+		}
+
+		var declared EnvSchema
+		if err := json.Unmarshal(content, &declared); err != nil {
+			return schema, fmt.Errorf("%s is not valid JSON: %w", path, err)
+		}
+
+		for i, v := range declared.Variables {
+
+			if strings.TrimSpace(v.Name) == "" {
+				return schema, fmt.Errorf("%s variable %d has no name", path, i+1)
+			}
+			if strings.TrimSpace(v.Description) == "" {
+				return schema, fmt.Errorf("%s declares %s with no description", path, v.Name)
+			}
+			if first, ok := seen[v.Name]; ok {
+				return schema, fmt.Errorf("%s declares %s, already declared in %s", path, v.Name, first)
+			}
+
+			seen[v.Name] = path
+		}
+
+		schema.Variables = append(schema.Variables, declared.Variables...)
+	}
+
+	return schema, nil
+}
+
+// Declares reports whether the schema has a variable called name.
+func (s EnvSchema) Declares(name string) bool {
+
+	return slices.ContainsFunc(s.Variables, func(v EnvVar) bool { return v.Name == name })
+}
+
+// For returns the variables a service of this configuration reads, with
+// Default, Example and Compose rendered.
+func (s EnvSchema) For(cfg *config.ServiceConfig) ([]EnvVar, error) {
+
+	features := append([]string{cfg.DatabaseDriver}, cfg.Features...)
+
+	var vars []EnvVar
+
+	for _, v := range s.Variables {
+
+		if v.When != "" && !slices.Contains(features, v.When) {
+			continue
+		}
+		if len(v.Types) > 0 && !slices.Contains(v.Types, cfg.Type) {
+			continue
+		}
+
+		for _, field := range []*string{&v.Default, &v.Example, &v.Compose} {
+
+			rendered, err := renderValue(*field, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", v.Name, err)
+			}
+
+			*field = rendered
+		}
+
+		vars = append(vars, v)
+	}
+
+	return vars, nil
+}
+
+// envGroups splits vars into their groups, in order of first appearance.
+func envGroups(vars []EnvVar) [][]EnvVar {
+
+	var (
+		groups [][]EnvVar
+		index  = map[string]int{}
+	)
+
+	for _, v := range vars {
+
+		i, ok := index[v.Group]
+		if !ok {
+
+			i = len(groups)
+			index[v.Group] = i
+			groups = append(groups, nil)
+		}
+
+		groups[i] = append(groups[i], v)
+	}
+
+	return groups
+}
+
+// settable reports whether a variable is a real key rather than a pattern
+// such as <queue>_workers.
+func (v EnvVar) settable() bool {
+
+	return !strings.ContainsAny(v.Name, "<>")
+}
+
+// EnvExample renders .env.example. Required variables and those with an
+// example are set; the rest are commented out at their default. Secrets are
+// always left empty.
+func EnvExample(vars []EnvVar) string {
+
+	var b strings.Builder
+
+	b.WriteString("# Generated by gomicrogen. Copy to .env and fill in the secrets;\n")
+	b.WriteString("# ENVIRONMENT.md describes every variable.\n")
+
+	for _, group := range envGroups(vars) {
+
+		fmt.Fprintf(&b, "\n# %s\n", group[0].Group)
+
+		for _, v := range group {
+
+			note := v.Description
+			if v.Required {
+				note += " (required)"
+			}
+
+			fmt.Fprintf(&b, "# %s\n", note)
+
+			switch {
+			case v.Secret && v.settable() && v.Required:
+				fmt.Fprintf(&b, "%s=\n", v.Name)
+			case v.Secret || !v.settable() || (!v.Required && v.Example == ""):
+				fmt.Fprintf(&b, "# %s=%s\n", v.Name, v.Default)
+			default:
+				fmt.Fprintf(&b, "%s=%s\n", v.Name, v.Example)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// EnvReference renders ENVIRONMENT.md, a table of every variable per group.
+func EnvReference(serviceName string, vars []EnvVar) string {
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s environment\n\n", serviceName)
+	b.WriteString("Generated by gomicrogen. `app/config` reads every variable below once at startup and\n")
+	b.WriteString("refuses to start when a required one is missing or any is malformed. Run the service\n")
+	b.WriteString("with `--print-config` to see the values it would use.\n")
+
+	for _, group := range envGroups(vars) {
+
+		fmt.Fprintf(&b, "\n## %s\n\n", group[0].Group)
+		b.WriteString("| Variable | Required | Default | Description |\n")
+		b.WriteString("|---|---|---|---|\n")
+
+		for _, v := range group {
+
+			required := ""
+			if v.Required {
+				required = "yes"
+			}
+
+			def := ""
+			if v.Default != "" {
+				def = "`" + v.Default + "`"
+			}
+
+			description := v.Description
+			if v.Secret {
+				description += ". Secret: `--print-config` redacts it"
+			}
+
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", v.Name, required, def, strings.ReplaceAll(description, "|", `\|`))
+		}
+	}
+
+	return b.String()
+}
+
+// ComposeEnvironment renders the entries of a compose environment: block,
+// indented for a service in docker-compose-local.yml. A variable is set when
+// it is required or has a value for the container.
+func ComposeEnvironment(vars []EnvVar) string {
+
+	const indent = "      "
+
+	var blocks []string
+
+	for _, group := range envGroups(vars) {
+
+		lines := []string{indent + "# " + group[0].Group}
+
+		for _, v := range group {
+
+			value := v.Compose
+			if value == "" {
+				value = v.Example
+			}
+
+			if !v.settable() || (value == "" && !v.Required) {
+				continue
+			}
+
+			lines = append(lines, indent+v.Name+": "+yamlScalar(value))
+		}
+
+		if len(lines) > 1 {
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
+
+// yamlScalar quotes a value YAML would otherwise read as something else, or
+// not at all. A JSON string is a valid double-quoted YAML scalar.
+func yamlScalar(value string) string {
+
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value, ":#{}[],&*!|>'\"%@`") {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// WriteEnvFiles writes .env.example and ENVIRONMENT.md into the service.
+func WriteEnvFiles(targetDir, serviceName string, vars []EnvVar) error {
+
+	files := []struct{ name, content string }{
+		{".env.example", EnvExample(vars)},
+		{"ENVIRONMENT.md", EnvReference(serviceName, vars)},
+	}
+
+	for _, f := range files {
+
+		path := filepath.Join(targetDir, f.name)
+
+		if err := os.WriteFile(path, []byte(f.content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+
+		fmt.Printf("Generated: %s\n", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Choplife-group/gomicrogen/internal/config"
+)
+
+const baseEnv = `{"variables": [
+  {"name": "SYSTEM_PORT", "group": "Service", "description": "HTTP port", "required": true, "example": "{{ .Port }}", "compose": "80"},
+  {"name": "SESSION_SECRET", "group": "Service", "description": "Cookie key", "required": true, "secret": true, "compose": "local"},
+  {"name": "DATABASE_PASSWORD", "group": "Database", "description": "Password", "secret": true, "example": "{{ .DatabasePassword }}"},
+  {"name": "DATABASE_SSL_MODE", "group": "Database", "description": "sslmode", "default": "disable", "when": "postgres"},
+  {"name": "RATE_LIMIT", "group": "Service", "description": "Requests per second", "default": "20"},
+  {"name": "QUEUES", "group": "RabbitMQ", "description": "Queues to consume", "example": "withdraw", "when": "queue"},
+  {"name": "<queue>_workers", "group": "RabbitMQ", "description": "Consumers per queue", "default": "1", "when": "queue"},
+  {"name": "CASINO_ONLY", "group": "Service", "description": "Casino only", "example": "x", "types": ["casino"]}
+]}`
+
+// envLayout builds base/ with env.json and the given Go template, and a
+// casino overlay.
+func envLayout(t *testing.T, baseSource string) Layout {
+	t.Helper()
+
+	l := nestedLayout(t, "casino")
+
+	for path, body := range map[string]string{
+		filepath.Join(l.BaseDir, EnvSchemaFile):                     baseEnv,
+		filepath.Join(l.BaseDir, "app", "config", "config.go.tmpl"): baseSource,
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	return l
+}
+
+func names(vars []EnvVar) []string {
+
+	var out []string
+	for _, v := range vars {
+		out = append(out, v.Name)
+	}
+
+	return out
+}
+
+func TestEnvSchemaForFiltersByFeatureTypeAndDriver(t *testing.T) {
+
+	l := envLayout(t, "")
+
+	schema, err := ReadEnvSchema(l.BaseDir)
+	if err != nil {
+		t.Fatalf("ReadEnvSchema: %v", err)
+	}
+
+	cfg := config.NewServiceConfig("svc")
+	cfg.Type = "payment"
+	cfg.Features = []string{"grpc", "queue"}
+	cfg.Port = "9000"
+
+	vars, err := schema.For(cfg)
+	if err != nil {
+		t.Fatalf("For: %v", err)
+	}
+
+	want := []string{"SYSTEM_PORT", "SESSION_SECRET", "DATABASE_PASSWORD", "RATE_LIMIT", "QUEUES", "<queue>_workers"}
+	if !slices.Equal(names(vars), want) {
+		t.Errorf("payment/mysql vars = %v, want %v", names(vars), want)
+	}
+	if vars[0].Example != "9000" {
+		t.Errorf("example = %q, want it rendered with the config", vars[0].Example)
+	}
+
+	cfg.Type = "casino"
+	cfg.Features = []string{"grpc"}
+	cfg.DatabaseDriver = config.DriverPostgres
+
+	vars, _ = schema.For(cfg)
+
+	if got := names(vars); !slices.Contains(got, "DATABASE_SSL_MODE") || !slices.Contains(got, "CASINO_ONLY") || slices.Contains(got, "QUEUES") {
+		t.Errorf("casino/postgres vars = %v", got)
+	}
+}
+
+func TestReadEnvSchemaRejectsDuplicatesAcrossTrees(t *testing.T) {
+
+	l := envLayout(t, "")
+	overlay := filepath.Join(l.TypesDir, "casino")
+
+	if err := os.WriteFile(filepath.Join(overlay, EnvSchemaFile), []byte(`{"variables": [{"name": "RATE_LIMIT", "description": "again"}]}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if _, err := ReadEnvSchema(l.BaseDir, overlay); err == nil || !strings.Contains(err.Error(), "RATE_LIMIT, already declared") {
+		t.Errorf("ReadEnvSchema = %v, want the duplicate named", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(overlay, EnvSchemaFile), []byte(`{"variables": [{"name": "UNDOCUMENTED"}]}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if _, err := ReadEnvSchema(l.BaseDir, overlay); err == nil || !strings.Contains(err.Error(), "UNDOCUMENTED with no description") {
+		t.Errorf("ReadEnvSchema = %v, want the missing description reported", err)
+	}
+}
+
+func TestEnvFilesAgree(t *testing.T) {
+
+	vars := []EnvVar{
+		{Name: "SYSTEM_PORT", Group: "Service", Description: "HTTP port", Required: true, Example: "9000", Compose: "80"},
+		{Name: "SESSION_SECRET", Group: "Service", Description: "Cookie key", Required: true, Secret: true, Compose: "local"},
+		{Name: "RATE_LIMIT", Group: "Service", Description: "Requests | second", Default: "20"},
+		{Name: "DATABASE_PASSWORD", Group: "Database", Description: "Password", Secret: true, Example: "p@ss:word"},
+		{Name: "<queue>_workers", Group: "RabbitMQ", Description: "Consumers per queue", Default: "1"},
+	}
+
+	example := EnvExample(vars)
+
+	for _, want := range []string{
+		"\n# Service\n# HTTP port (required)\nSYSTEM_PORT=9000\n",
+		"SESSION_SECRET=\n",
+		"# RATE_LIMIT=20\n",
+		"# DATABASE_PASSWORD=\n",
+		"# <queue>_workers=1\n",
+	} {
+		if !strings.Contains(example, want) {
+			t.Errorf(".env.example is missing %q:\n%s", want, example)
+		}
+	}
+	if strings.Contains(example, "p@ss") {
+		t.Errorf(".env.example must never carry a secret:\n%s", example)
+	}
+
+	compose := ComposeEnvironment(vars)
+
+	want := "      # Service\n      SYSTEM_PORT: 80\n      SESSION_SECRET: local\n\n      # Database\n      DATABASE_PASSWORD: \"p@ss:word\""
+	if compose != want {
+		t.Errorf("compose environment =\n%s\nwant\n%s", compose, want)
+	}
+
+	reference := EnvReference("svc", vars)
+
+	for _, want := range []string{
+		"# svc environment",
+		"| `SYSTEM_PORT` | yes |  | HTTP port |",
+		"| `RATE_LIMIT` |  | `20` | Requests \\| second |",
+		"| `<queue>_workers` |  | `1` | Consumers per queue |",
+	} {
+		if !strings.Contains(reference, want) {
+			t.Errorf("ENVIRONMENT.md is missing %q:\n%s", want, reference)
+		}
+	}
+}
+
+func TestGenerateServiceWritesEnvFiles(t *testing.T) {
+
+	l := envLayout(t, "package config")
+
+	compose := "    environment:\n{{ environment }}\n"
+	if err := os.WriteFile(filepath.Join(l.BaseDir, "docker-compose-local.yml.tmpl"), []byte(compose), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cfg := config.NewServiceConfig("svc")
+	cfg.Type = "casino"
+
+	target := t.TempDir()
+
+	if err := NewTemplateGenerator(l, filepath.Join(l.TypesDir, "casino"), cfg).GenerateService(target); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	for _, name := range []string{".env.example", "ENVIRONMENT.md"} {
+		if _, err := os.Stat(filepath.Join(target, name)); err != nil {
+			t.Errorf("%s was not written", name)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(target, EnvSchemaFile)); err == nil {
+		t.Error("env.json must not be emitted into the generated service")
+	}
+
+	got, _ := os.ReadFile(filepath.Join(target, "docker-compose-local.yml"))
+	if !strings.Contains(string(got), "      SYSTEM_PORT: 80\n") || !strings.Contains(string(got), "      CASINO_ONLY: x\n") {
+		t.Errorf("compose environment not rendered from env.json:\n%s", got)
+	}
+}
+
+func TestLintEnvFlagsUndeclaredReads(t *testing.T) {
+
+	l := envLayout(t, `package config
+
+func load() {
+	_ = l.port("SYSTEM_PORT", "")
+	_ = l.str("TYPO_PORT", "8080")
+	_, _ = os.LookupEnv("RATE_LIMIT")
+	l.problem("%s is required", key)
+}
+`)
+
+	overlayRouter := filepath.Join(l.TypesDir, "casino", "app", "router", "router.go.tmpl")
+	if err := os.MkdirAll(filepath.Dir(overlayRouter), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(overlayRouter, []byte(`a.onClose("database", a.DB.Close)
+host := os.Getenv("PROVIDER_URL")
+`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	findings, err := LintEnv(l)
+	if err != nil {
+		t.Fatalf("LintEnv: %v", err)
+	}
+
+	want := []string{
+		"base/app/config/config.go.tmpl:5: TYPO_PORT is read but not declared in env.json",
+		"types/casino/app/router/router.go.tmpl:2: PROVIDER_URL is read but not declared in env.json",
+	}
+
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("findings =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// declaring it in the overlay's own env.json satisfies that overlay
+	if err := os.WriteFile(filepath.Join(l.TypesDir, "casino", EnvSchemaFile), []byte(`{"variables": [{"name": "PROVIDER_URL", "description": "Provider"}]}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	findings, _ = LintEnv(l)
+	if len(findings) != 1 || findings[0].Key != "TYPO_PORT" {
+		t.Errorf("findings = %v, want only TYPO_PORT", findings)
+	}
+}
@@ -0,0 +1,144 @@
+package generator
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envRead matches a literal key read through os.Getenv, os.LookupEnv or the
+// generated app/config loader (l.required("KEY"), l.port("KEY", ...) and so
+// on). Keys built at runtime, such as <queue>_workers, cannot be seen.
+var envRead = regexp.MustCompile(`(?:\bos\.(?:Getenv|LookupEnv)|\bl\.(?:str|required|secret|integer|seconds|port|boolean|list|secondsList|cidrs|oneOf))\(\s*"([A-Za-z_][A-Za-z0-9_]*)"`)
+
+// EnvFinding is a variable a template reads without declaring it.
+type EnvFinding struct {
+	// File is relative to the templates root, with a forward-slash path
+	File string
+	Line int
+	Key  string
+}
+
+func (f EnvFinding) String() string {
+
+	return fmt.Sprintf("%s:%d: %s is read but not declared in %s", f.File, f.Line, f.Key, EnvSchemaFile)
+}
+
+// LintEnv reports every variable the Go templates read that no env.json
+// declares. Base templates are checked against the base schema, since they
+// reach every type; an overlay's templates may also use what the overlay
+// declares. The schemas themselves must parse.
+func LintEnv(l Layout) ([]EnvFinding, error) {
+
+	base, err := ReadEnvSchema(l.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := lintTree(l.Root, l.BaseDir, base, l.Legacy)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range l.Types() {
+
+		dir := filepath.Join(l.TypesDir, t.Name)
+
+		schema, err := ReadEnvSchema(l.BaseDir, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		found, err := lintTree(l.Root, dir, schema, false)
+		if err != nil {
+			return nil, err
+		}
+
+		findings = append(findings, found...)
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+
+	return findings, nil
+}
+
+// lintTree scans the Go templates under dir. A legacy flat root also holds
+// base/ and types/, which are not its own.
+func lintTree(root, dir string, schema EnvSchema, legacy bool) ([]EnvFinding, error) {
+
+	var findings []EnvFinding
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+
+		if d.IsDir() {
+			if legacy && (rel == "base" || rel == "types") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if shouldSkipFile(rel) || shouldCopyAsIs(path) {
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, ".go.tmpl") {
+			return nil
+		}
+
+		found, err := lintFile(path, schema)
+		if err != nil {
+			return err
+		}
+
+		display, _ := filepath.Rel(root, path)
+
+		for _, f := range found {
+
+			f.File = filepath.ToSlash(display)
+			findings = append(findings, f)
+		}
+
+		return nil
+	})
+
+	return findings, err
+}
+
+func lintFile(path string, schema EnvSchema) ([]EnvFinding, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var findings []EnvFinding
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+
+		for _, match := range envRead.FindAllStringSubmatch(scanner.Text(), -1) {
+			if !schema.Declares(match[1]) {
+				findings = append(findings, EnvFinding{Line: line, Key: match[1]})
+			}
+		}
+	}
+
+	return findings, scanner.Err()
+}
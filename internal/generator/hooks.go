@@ -0,0 +1,204 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Choplife-group/gomicrogen/internal/config"
+)
+
+// Hook is a command a type runs once its service has been rendered, such as
+// compiling the overlay's protobuf or refreshing docs/ with swag.
+type Hook struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+
+	// Dir is relative to the service root; empty means the root itself.
+	Dir string `json:"dir,omitempty"`
+
+	// Optional hooks report a failure or a missing command without failing
+	// generation.
+	Optional bool `json:"optional,omitempty"`
+
+	// When names a feature the hook needs; the hook is skipped without it.
+	When string `json:"when,omitempty"`
+}
+
+// Features every generation can be conditioned on, alongside the database
+// driver name and the features a type declares in its type.json.
+const (
+	FeatureGoMod = "go-mod"
+	FeatureGit   = "git"
+)
+
+// label is how a hook is named in output.
+func (h Hook) label() string {
+
+	if h.Name != "" {
+		return h.Name
+	}
+
+	return strings.TrimSpace(h.Command + " " + strings.Join(h.Args, " "))
+}
+
+// HookStatus is the outcome of one hook.
+type HookStatus string
+
+const (
+	HookSucceeded HookStatus = "succeeded"
+	HookFailed    HookStatus = "failed"
+	HookSkipped   HookStatus = "skipped"
+)
+
+// HookResult records what a hook did, with its combined output captured rather
+// than streamed, so a caller can show it only when something went wrong.
+type HookResult struct {
+	Name     string
+	Command  []string
+	Dir      string
+	Status   HookStatus
+	Optional bool
+	Reason   string
+	Output   string
+	Duration time.Duration
+}
+
+// CommandRunner runs one command in dir and returns its combined output.
+type CommandRunner func(ctx context.Context, dir, name string, args ...string) ([]byte, error)
+
+// ExecCommand is the CommandRunner used outside tests. A command that is not
+// on PATH is reported as exec.ErrNotFound.
+func ExecCommand(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+
+	return cmd.CombinedOutput()
+}
+
+// RunHooks runs hooks in order inside serviceDir. Hook arguments and working
+// directories are templates rendered with the service configuration. It stops
+// at the first required hook that fails and returns an error naming it; the
+// results up to that point are returned either way.
+func RunHooks(ctx context.Context, serviceDir string, hooks []Hook, features []string, cfg *config.ServiceConfig, run CommandRunner) ([]HookResult, error) {
+
+	if run == nil {
+		run = ExecCommand
+	}
+
+	results := make([]HookResult, 0, len(hooks))
+
+	for _, hook := range hooks {
+
+		result := HookResult{Name: hook.label(), Optional: hook.Optional, Status: HookSkipped}
+
+		if hook.When != "" && !slices.Contains(features, hook.When) {
+
+			result.Reason = fmt.Sprintf("needs %s", hook.When)
+			results = append(results, result)
+
+			continue
+		}
+
+		args, dir, err := renderHook(hook, cfg)
+		if err != nil {
+			return results, fmt.Errorf("hook %s: %w", hook.label(), err)
+		}
+
+		result.Command = append([]string{hook.Command}, args...)
+		result.Dir = filepath.Join(serviceDir, dir)
+
+		started := time.Now()
+		output, err := run(ctx, result.Dir, hook.Command, args...)
+		result.Duration = time.Since(started)
+		result.Output = string(output)
+
+		if err == nil {
+
+			result.Status = HookSucceeded
+			results = append(results, result)
+
+			continue
+		}
+
+		result.Status = HookFailed
+		result.Reason = err.Error()
+
+		if isNotFound(err) {
+			result.Reason = fmt.Sprintf("%s is not installed", hook.Command)
+		}
+
+		results = append(results, result)
+
+		if !hook.Optional {
+			return results, fmt.Errorf("hook %s failed: %s", hook.label(), result.Reason)
+		}
+	}
+
+	return results, nil
+}
+
+// renderValue renders a template string from a manifest, such as a hook
+// argument or an env.json example, with the service configuration.
+func renderValue(s string, cfg *config.ServiceConfig) (string, error) {
+
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	tmpl, err := template.New("value").Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, cfg); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+func renderHook(hook Hook, cfg *config.ServiceConfig) ([]string, string, error) {
+
+	render := func(s string) (string, error) { return renderValue(s, cfg) }
+
+	args := make([]string, 0, len(hook.Args))
+
+	for _, arg := range hook.Args {
+
+		rendered, err := render(arg)
+		if err != nil {
+			return nil, "", err
+		}
+
+		args = append(args, rendered)
+	}
+
+	dir, err := render(hook.Dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if filepath.IsAbs(dir) || strings.HasPrefix(filepath.Clean(dir), "..") {
+		return nil, "", fmt.Errorf("dir %q must be inside the service", hook.Dir)
+	}
+
+	return args, dir, nil
+}
+
+func isNotFound(err error) bool {
+
+	var execErr *exec.Error
+
+	return errors.As(err, &execErr) && errors.Is(execErr.Err, exec.ErrNotFound)
+}
@@ -0,0 +1,224 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Choplife-group/gomicrogen/internal/config"
+)
+
+// call is one command the fake runner saw.
+type call struct {
+	dir  string
+	name string
+	args []string
+}
+
+// fakeRunner records calls and fails the commands named in failing.
+func fakeRunner(calls *[]call, failing map[string]error) CommandRunner {
+
+	return func(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+
+		*calls = append(*calls, call{dir: dir, name: name, args: args})
+
+		if err, ok := failing[name]; ok {
+			return []byte(name + " went wrong"), err
+		}
+
+		return []byte(name + " ok"), nil
+	}
+}
+
+func TestHooksRunInOrderWithRenderedArgs(t *testing.T) {
+
+	cfg := config.NewServiceConfig("svc")
+	cfg.ModuleName = "github.com/test-org/svc"
+
+	hooks := []Hook{
+		{Name: "protobuf", Command: "protoc", Args: []string{"--go_opt=module={{ .ModuleName }}", "app/grpc/wallet/wallet-service.proto"}},
+		{Command: "swag", Args: []string{"init"}, Dir: "."},
+		{Command: "golangci-lint", Args: []string{"run", "--fix"}, Dir: "app"},
+	}
+
+	var calls []call
+
+	results, err := RunHooks(context.Background(), "/srv/svc", hooks, nil, cfg, fakeRunner(&calls, nil))
+	if err != nil {
+		t.Fatalf("RunHooks: %v", err)
+	}
+
+	if len(calls) != 3 || calls[0].name != "protoc" || calls[1].name != "swag" || calls[2].name != "golangci-lint" {
+		t.Fatalf("hooks must run in declaration order, got %+v", calls)
+	}
+
+	if calls[0].args[0] != "--go_opt=module=github.com/test-org/svc" {
+		t.Errorf("args must be rendered with the service config, got %q", calls[0].args[0])
+	}
+	if calls[2].dir != filepath.Join("/srv/svc", "app") {
+		t.Errorf("dir must be relative to the service root, got %q", calls[2].dir)
+	}
+
+	for _, r := range results {
+		if r.Status != HookSucceeded {
+			t.Errorf("%s = %s, want succeeded", r.Name, r.Status)
+		}
+	}
+
+	if results[0].Name != "protobuf" || results[1].Name != "swag init" {
+		t.Errorf("results must be labelled by name or command, got %q and %q", results[0].Name, results[1].Name)
+	}
+	if results[0].Output != "protoc ok" {
+		t.Errorf("output must be captured, got %q", results[0].Output)
+	}
+}
+
+func TestHooksConditionedOnMissingFeaturesAreSkipped(t *testing.T) {
+
+	hooks := []Hook{
+		{Command: "swag", Args: []string{"init"}, When: FeatureGoMod},
+		{Command: "protoc", When: "grpc"},
+	}
+
+	var calls []call
+
+	results, err := RunHooks(context.Background(), t.TempDir(), hooks, []string{"grpc"}, config.NewServiceConfig("svc"), fakeRunner(&calls, nil))
+	if err != nil {
+		t.Fatalf("RunHooks: %v", err)
+	}
+
+	if len(calls) != 1 || calls[0].name != "protoc" {
+		t.Fatalf("only the hook whose feature is present must run, got %+v", calls)
+	}
+	if results[0].Status != HookSkipped || !strings.Contains(results[0].Reason, FeatureGoMod) {
+		t.Errorf("skipped hook = %+v, want a reason naming the feature", results[0])
+	}
+}
+
+func TestOptionalHookFailureDoesNotStopGeneration(t *testing.T) {
+
+	hooks := []Hook{
+		{Command: "golangci-lint", Optional: true},
+		{Command: "swag"},
+	}
+
+	var calls []call
+
+	results, err := RunHooks(context.Background(), t.TempDir(), hooks, nil, config.NewServiceConfig("svc"),
+		fakeRunner(&calls, map[string]error{"golangci-lint": errors.New("exit status 1")}))
+	if err != nil {
+		t.Fatalf("an optional failure must not be an error: %v", err)
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("later hooks must still run, got %+v", calls)
+	}
+	if results[0].Status != HookFailed || results[0].Output != "golangci-lint went wrong" {
+		t.Errorf("failed optional hook = %+v, want failed with its output captured", results[0])
+	}
+}
+
+func TestRequiredHookFailureStops(t *testing.T) {
+
+	hooks := []Hook{
+		{Command: "protoc"},
+		{Command: "swag"},
+	}
+
+	var calls []call
+
+	results, err := RunHooks(context.Background(), t.TempDir(), hooks, nil, config.NewServiceConfig("svc"),
+		fakeRunner(&calls, map[string]error{"protoc": errors.New("exit status 1")}))
+	if err == nil || !strings.Contains(err.Error(), "protoc") {
+		t.Fatalf("err = %v, want the failing hook named", err)
+	}
+
+	if len(calls) != 1 || len(results) != 1 {
+		t.Errorf("nothing must run after a failed required hook, got %+v", calls)
+	}
+}
+
+func TestMissingCommandIsReportedAsNotInstalled(t *testing.T) {
+
+	hooks := []Hook{{Command: "gomicrogen-no-such-tool", Optional: true}}
+
+	results, err := RunHooks(context.Background(), t.TempDir(), hooks, nil, config.NewServiceConfig("svc"), ExecCommand)
+	if err != nil {
+		t.Fatalf("RunHooks: %v", err)
+	}
+
+	if results[0].Status != HookFailed || !strings.Contains(results[0].Reason, "not installed") {
+		t.Errorf("result = %+v, want a not-installed failure", results[0])
+	}
+}
+
+func TestHookDirMustStayInsideTheService(t *testing.T) {
+
+	for _, dir := range []string{"..", "../other", "/etc"} {
+
+		hooks := []Hook{{Command: "swag", Dir: dir}}
+
+		var calls []call
+
+		if _, err := RunHooks(context.Background(), t.TempDir(), hooks, nil, config.NewServiceConfig("svc"), fakeRunner(&calls, nil)); err == nil {
+			t.Errorf("dir %q must be rejected", dir)
+		}
+		if len(calls) != 0 {
+			t.Errorf("dir %q: nothing must run", dir)
+		}
+	}
+}
+
+func TestReadTypeManifestParsesHooks(t *testing.T) {
+
+	dir := t.TempDir()
+
+	manifest := `{
+  "description": "payment",
+  "features": ["grpc", "queue"],
+  "hooks": [
+    {"name": "swagger", "command": "swag", "args": ["init"], "optional": true, "when": "go-mod"}
+  ]
+}`
+	if err := os.WriteFile(filepath.Join(dir, "type.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	m, err := ReadTypeManifest(dir)
+	if err != nil {
+		t.Fatalf("ReadTypeManifest: %v", err)
+	}
+
+	if len(m.Features) != 2 || len(m.Hooks) != 1 {
+		t.Fatalf("manifest = %+v", m)
+	}
+
+	h := m.Hooks[0]
+	if h.Name != "swagger" || h.Command != "swag" || !h.Optional || h.When != FeatureGoMod || h.Args[0] != "init" {
+		t.Errorf("hook = %+v", h)
+	}
+}
+
+func TestReadTypeManifestRejectsHookWithoutCommand(t *testing.T) {
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "type.json"), []byte(`{"hooks": [{"name": "empty"}]}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if _, err := ReadTypeManifest(dir); err == nil {
+		t.Error("a hook without a command must be rejected")
+	}
+}
+
+func TestReadTypeManifestWithoutFile(t *testing.T) {
+
+	for _, dir := range []string{"", t.TempDir()} {
+		if m, err := ReadTypeManifest(dir); err != nil || len(m.Hooks) != 0 {
+			t.Errorf("ReadTypeManifest(%q) = %+v, %v, want an empty manifest", dir, m, err)
+		}
+	}
+}
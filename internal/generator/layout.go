@@ -96,25 +96,92 @@ func (l Layout) Types() []TypeInfo {
 	return types
 }
 
-// readTypeDescription reads the optional type.json manifest describing a type.
-func readTypeDescription(dir string) string {
+// TypeManifest is the optional type.json describing a type.
+type TypeManifest struct {
+	Description string `json:"description"`
 
-	content, err := os.ReadFile(filepath.Join(dir, "type.json"))
-	if err != nil {
-		return ""
+	// Features are the capabilities this type brings, which hooks can be
+	// conditioned on — e.g. grpc for the overlays that ship a .proto.
+	Features []string `json:"features,omitempty"`
+
+	// Hooks run in order once the service has been rendered.
+	Hooks []Hook `json:"hooks,omitempty"`
+}
+
+// ReadTypeManifest reads the type.json in an overlay directory. A missing
+// manifest, or an empty overlayDir for base-only generation, is not an error.
+func ReadTypeManifest(overlayDir string) (TypeManifest, error) {
+
+	var manifest TypeManifest
+
+	if overlayDir == "" {
+		return manifest, nil
 	}
 
-	var manifest struct {
-		Description string `json:"description"`
+	content, err := os.ReadFile(filepath.Join(overlayDir, "type.json"))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, fmt.Errorf("type.json is unreadable: %w", err)
 	}
 
 	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, fmt.Errorf("type.json is not valid JSON: %w", err)
+	}
+
+	for i, hook := range manifest.Hooks {
+		if strings.TrimSpace(hook.Command) == "" {
+			return manifest, fmt.Errorf("type.json hook %d has no command", i+1)
+		}
+	}
+
+	return manifest, nil
+}
+
+// readTypeDescription reads the description from a type's manifest.
+func readTypeDescription(dir string) string {
+
+	manifest, err := ReadTypeManifest(dir)
+	if err != nil {
 		return ""
 	}
 
 	return manifest.Description
 }
 
+// routerTemplate is the file every overlay must supply: the base tree has no
+// app/router/router.go, so a type without one generates a service that does not
+// compile.
+const routerTemplate = "app/router/router.go.tmpl"
+
+// ValidateType reports whether a type directory can produce a working service:
+// its type.json, when present, must parse with a command for every hook, and
+// the overlay must supply the router.
+func (l Layout) ValidateType(name string) error {
+
+	if l.TypesDir == "" {
+		return fmt.Errorf("no types directory in %s", l.Root)
+	}
+
+	dir := filepath.Join(l.TypesDir, name)
+
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("type %q not found in %s", name, l.TypesDir)
+	}
+
+	if _, err := ReadTypeManifest(dir); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, routerTemplate)); err != nil {
+		return fmt.Errorf("overlay does not supply %s", routerTemplate)
+	}
+
+	return nil
+}
+
 // ResolveType maps a --type value to its canonical name and overlay directory.
 // An empty overlay directory means base-only generation.
 func (l Layout) ResolveType(name string) (string, string, error) {
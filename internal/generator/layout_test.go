@@ -238,6 +238,62 @@ func TestResolveTypeGeneralInLegacyModeStillWorks(t *testing.T) {
 	}
 }
 
+func TestValidateType(t *testing.T) {
+
+	l := nestedLayout(t, "good", "norouter", "badjson")
+
+	for _, name := range []string{"good", "badjson"} {
+
+		router := filepath.Join(l.TypesDir, name, "app", "router", "router.go.tmpl")
+		if err := os.MkdirAll(filepath.Dir(router), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(router, []byte("package router"), 0o644); err != nil {
+			t.Fatalf("write router: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(l.TypesDir, "badjson", "type.json"), []byte("{"), 0o644); err != nil {
+		t.Fatalf("write type.json: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		wantErr string
+	}{
+		{"good", ""},
+		// without an overlay router the service does not compile
+		{"norouter", "router.go.tmpl"},
+		{"badjson", "type.json"},
+		{"missing", "not found"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			err := l.ValidateType(tc.name)
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateType(%q) = %v, want nil", tc.name, err)
+				}
+				return
+			}
+
+			if err == nil || !contains(err.Error(), tc.wantErr) {
+				t.Errorf("ValidateType(%q) = %v, want an error mentioning %q", tc.name, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTypeInLegacyMode(t *testing.T) {
+
+	if err := ResolveLayout(t.TempDir()).ValidateType("general"); err == nil {
+		t.Error("a legacy layout has no types to validate")
+	}
+}
+
 func contains(haystack, needle string) bool {
 
 	return len(haystack) >= len(needle) && (func() bool {
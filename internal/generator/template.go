@@ -21,6 +21,9 @@ type TemplateGenerator struct {
 	layout     Layout
 	overlayDir string
 	config     *config.ServiceConfig
+
+	// env is what the templates' env.json declares for this service
+	env []EnvVar
 }
 
 // NewTemplateGenerator creates a new template generator. overlayDir is the
@@ -42,17 +45,35 @@ func (tg *TemplateGenerator) GenerateService(targetDir string) error {
 		return fmt.Errorf("failed to create target directory: %w", err)
 	}
 
+	// read first: the compose template renders its environment from it
+	schema, err := ReadEnvSchema(tg.layout.BaseDir, tg.overlayDir)
+	if err != nil {
+		return err
+	}
+
+	if tg.env, err = schema.For(tg.config); err != nil {
+		return fmt.Errorf("%s: %w", EnvSchemaFile, err)
+	}
+
 	if err := tg.renderTree(tg.layout.BaseDir, targetDir, true); err != nil {
 		return err
 	}
 
-	if tg.overlayDir == "" {
-		return nil
+	if tg.overlayDir != "" {
+
+		fmt.Printf("Applying '%s' overlay...\n", tg.config.Type)
+
+		if err := tg.renderTree(tg.overlayDir, targetDir, false); err != nil {
+			return err
+		}
 	}
 
-	fmt.Printf("Applying '%s' overlay...\n", tg.config.Type)
+	// legacy templates declare nothing
+	if len(tg.env) == 0 {
+		return nil
+	}
 
-	return tg.renderTree(tg.overlayDir, targetDir, false)
+	return WriteEnvFiles(targetDir, tg.config.ServiceName, tg.env)
 }
 
 // renderTree walks a single template tree and renders it into targetDir.
@@ -92,6 +113,11 @@ func (tg *TemplateGenerator) renderTree(srcRoot, targetDir string, isBase bool)
 			return nil
 		}
 
+		// Nor is the env schema; it becomes .env.example and ENVIRONMENT.md
+		if relPath == EnvSchemaFile {
+			return nil
+		}
+
 		// Determine target path
 		targetPath := filepath.Join(targetDir, relPath)
 
@@ -227,6 +253,8 @@ func (tg *TemplateGenerator) generateFile(templatePath, targetPath string) error
 			return string(a)
 		},
 		"escape": html.EscapeString,
+		// the compose environment: block, from env.json
+		"environment": func() string { return ComposeEnvironment(tg.env) },
 	}
 
 	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(funcMap).Parse(string(content))
@@ -0,0 +1,97 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Choplife-group/gomicrogen/internal/config"
+)
+
+// FileName is written at the root of every generated service. It records how
+// the service was generated, so commands run later from inside the service —
+// and plugins — know its module, type and driver without parsing code.
+const FileName = ".gomicrogen.json"
+
+// Manifest describes a generated service.
+type Manifest struct {
+	ServiceName      string `json:"service_name"`
+	ModuleName       string `json:"module_name"`
+	Type             string `json:"type"`
+	DatabaseDriver   string `json:"database_driver"`
+	GRPCPort         string `json:"grpc_port,omitempty"`
+	GeneratorVersion string `json:"generator_version"`
+}
+
+// FromConfig builds the manifest for a service being generated.
+func FromConfig(c *config.ServiceConfig, generatorVersion string) Manifest {
+
+	return Manifest{
+		ServiceName:      c.ServiceName,
+		ModuleName:       c.ModuleName,
+		Type:             c.Type,
+		DatabaseDriver:   c.DatabaseDriver,
+		GRPCPort:         c.GRPCPort,
+		GeneratorVersion: generatorVersion,
+	}
+}
+
+// Write saves the manifest at the root of a service.
+func Write(serviceDir string, m Manifest) error {
+
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(serviceDir, FileName)
+
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Read loads a manifest file.
+func Read(path string) (Manifest, error) {
+
+	var m Manifest
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+
+	if err := json.Unmarshal(content, &m); err != nil {
+		return m, fmt.Errorf("%s is not a valid manifest: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// Find walks up from dir to the nearest service root and returns the path of
+// its manifest, or "" when dir is not inside a generated service.
+func Find(dir string) string {
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+
+		path := filepath.Join(dir, FileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+
+		dir = parent
+	}
+}
@@ -0,0 +1,78 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Choplife-group/gomicrogen/internal/config"
+)
+
+func TestWriteThenRead(t *testing.T) {
+
+	c := config.NewServiceConfig("pawapay-service")
+	c.Type = "payment"
+	c.DatabaseDriver = config.DriverPostgres
+
+	dir := t.TempDir()
+
+	if err := Write(dir, FromConfig(c, "v1.4.0")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	m, err := Read(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	want := Manifest{
+		ServiceName:      "pawapay-service",
+		ModuleName:       "github.com/choplife-group/pawapay-service",
+		Type:             "payment",
+		DatabaseDriver:   "postgres",
+		GRPCPort:         "8081",
+		GeneratorVersion: "v1.4.0",
+	}
+	if m != want {
+		t.Errorf("round trip = %+v, want %+v", m, want)
+	}
+}
+
+func TestReadRejectsGarbage(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if _, err := Read(path); err == nil {
+		t.Error("a corrupt manifest must be an error")
+	}
+}
+
+func TestFindWalksUpToTheServiceRoot(t *testing.T) {
+
+	root := t.TempDir()
+
+	if err := Write(root, Manifest{ServiceName: "svc"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	nested := filepath.Join(root, "app", "router")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	for _, dir := range []string{root, nested} {
+		if got := Find(dir); got != filepath.Join(root, FileName) {
+			t.Errorf("Find(%s) = %q, want the root manifest", dir, got)
+		}
+	}
+}
+
+func TestFindOutsideAService(t *testing.T) {
+
+	if got := Find(t.TempDir()); got != "" {
+		t.Errorf("Find outside a service = %q, want empty", got)
+	}
+}
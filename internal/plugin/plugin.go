@@ -0,0 +1,242 @@
+package plugin
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Prefix names a plugin executable: gomicrogen-psp-onboard is run for
+// `gomicrogen psp-onboard`.
+const Prefix = "gomicrogen-"
+
+// PathEnv lists extra plugin directories, separated like PATH. They are
+// searched before the default plugins directory and before PATH.
+const PathEnv = "GOMICROGEN_PLUGIN_PATH"
+
+// Context environment handed to every plugin.
+const (
+	EnvTemplatesDir = "GOMICROGEN_TEMPLATES_DIR"
+	EnvVersion      = "GOMICROGEN_VERSION"
+	EnvManifest     = "GOMICROGEN_MANIFEST"
+	EnvBinary       = "GOMICROGEN_BIN"
+)
+
+// ErrNotFound means no plugin executable matches the name.
+var ErrNotFound = errors.New("plugin not found")
+
+// Plugin is an executable discovered on disk.
+type Plugin struct {
+	Name string
+	Path string
+
+	// ShadowedBy is the path of an earlier plugin with the same name, which is
+	// the one that runs.
+	ShadowedBy string
+}
+
+// Finder searches plugin directories and then PATH, in order.
+type Finder struct {
+	Dirs []string
+	Path string
+}
+
+// NewFinder searches GOMICROGEN_PLUGIN_PATH, then the plugins directory beside
+// the binary (next to templates/, where the installers put things), then
+// ~/.gomicrogen/plugins, then PATH.
+func NewFinder(execDir string) Finder {
+
+	var dirs []string
+
+	if extra := os.Getenv(PathEnv); extra != "" {
+		dirs = append(dirs, filepath.SplitList(extra)...)
+	}
+
+	if execDir != "" {
+		dirs = append(dirs, filepath.Join(execDir, "plugins"))
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".gomicrogen", "plugins"))
+	}
+
+	return Finder{Dirs: dirs, Path: os.Getenv("PATH")}
+}
+
+func (f Finder) searchPath() []string {
+
+	dirs := append([]string{}, f.Dirs...)
+
+	return append(dirs, filepath.SplitList(f.Path)...)
+}
+
+// Find returns the executable for a plugin name.
+func (f Finder) Find(name string) (string, error) {
+
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "-") {
+		return "", ErrNotFound
+	}
+
+	for _, dir := range f.searchPath() {
+
+		if dir == "" {
+			continue
+		}
+
+		for _, candidate := range candidates(filepath.Join(dir, Prefix+name)) {
+			if isExecutable(candidate) {
+				return candidate, nil
+			}
+		}
+	}
+
+	return "", ErrNotFound
+}
+
+// List returns every plugin found, sorted by name. When a name appears more
+// than once, the first in search order runs and the rest are marked shadowed.
+func (f Finder) List() []Plugin {
+
+	var plugins []Plugin
+
+	first := map[string]string{}
+	seenDir := map[string]bool{}
+
+	for _, dir := range f.searchPath() {
+
+		if dir == "" || seenDir[dir] {
+			continue
+		}
+		seenDir[dir] = true
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), Prefix) {
+				continue
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+
+			name := strings.TrimPrefix(trimExecutableExt(entry.Name()), Prefix)
+			if name == "" {
+				continue
+			}
+
+			p := Plugin{Name: name, Path: path}
+
+			if winner, ok := first[name]; ok {
+				p.ShadowedBy = winner
+			} else {
+				first[name] = path
+			}
+
+			plugins = append(plugins, p)
+		}
+	}
+
+	sort.SliceStable(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+
+	return plugins
+}
+
+// Context is what a plugin learns about the gomicrogen that launched it.
+type Context struct {
+	TemplatesDir string
+	Version      string
+	Manifest     string
+	Binary       string
+}
+
+// Env returns the process environment extended with the plugin context.
+// Unknown values are omitted rather than exported empty.
+func (c Context) Env() []string {
+
+	env := os.Environ()
+
+	add := func(key, value string) {
+		if value != "" {
+			env = append(env, key+"="+value)
+		}
+	}
+
+	add(EnvTemplatesDir, c.TemplatesDir)
+	add(EnvVersion, c.Version)
+	add(EnvManifest, c.Manifest)
+	add(EnvBinary, c.Binary)
+
+	return env
+}
+
+// Run executes a plugin with the remaining arguments, wired to this process's
+// standard streams, and returns its exit code.
+func Run(path string, args []string, ctx Context) (int, error) {
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = ctx.Env()
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, err
+	}
+
+	return 0, nil
+}
+
+// candidates lists the file names a plugin may have on this platform.
+func candidates(base string) []string {
+
+	if runtime.GOOS != "windows" {
+		return []string{base}
+	}
+
+	return []string{base + ".exe", base + ".bat", base + ".cmd", base}
+}
+
+func trimExecutableExt(name string) string {
+
+	if runtime.GOOS != "windows" {
+		return name
+	}
+
+	for _, ext := range []string{".exe", ".bat", ".cmd"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+
+	return name
+}
+
+func isExecutable(path string) bool {
+
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	if runtime.GOOS == "windows" {
+		return true
+	}
+
+	return info.Mode().Perm()&0o111 != 0
+}
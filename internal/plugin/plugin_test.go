@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writePlugin(t *testing.T, dir, file string, mode os.FileMode) string {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	path := filepath.Join(dir, file)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nexit 0\n"), mode); err != nil {
+		t.Fatalf("write plugin: %v", err)
+	}
+
+	return path
+}
+
+func skipOnWindows(t *testing.T) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins here are shell scripts with an executable bit")
+	}
+}
+
+func TestFindPrefersPluginDirsOverPath(t *testing.T) {
+	skipOnWindows(t)
+
+	plugins, path := t.TempDir(), t.TempDir()
+
+	want := writePlugin(t, plugins, "gomicrogen-psp-onboard", 0o755)
+	writePlugin(t, path, "gomicrogen-psp-onboard", 0o755)
+
+	got, err := Finder{Dirs: []string{plugins}, Path: path}.Find("psp-onboard")
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if got != want {
+		t.Errorf("Find = %s, want the plugins directory copy %s", got, want)
+	}
+}
+
+func TestFindFallsBackToPath(t *testing.T) {
+	skipOnWindows(t)
+
+	path := t.TempDir()
+	want := writePlugin(t, path, "gomicrogen-lint", 0o755)
+
+	got, err := Finder{Dirs: []string{t.TempDir()}, Path: path}.Find("lint")
+	if err != nil || got != want {
+		t.Errorf("Find = %q, %v, want %s", got, err, want)
+	}
+}
+
+func TestFindIgnoresNonExecutablesAndBadNames(t *testing.T) {
+	skipOnWindows(t)
+
+	dir := t.TempDir()
+	writePlugin(t, dir, "gomicrogen-notes", 0o644)
+
+	f := Finder{Dirs: []string{dir}}
+
+	for _, name := range []string{"notes", "", "../notes", "-h", "missing"} {
+		if _, err := f.Find(name); err != ErrNotFound {
+			t.Errorf("Find(%q) = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestListMarksShadowedPlugins(t *testing.T) {
+	skipOnWindows(t)
+
+	first, second := t.TempDir(), t.TempDir()
+
+	winner := writePlugin(t, first, "gomicrogen-psp-onboard", 0o755)
+	writePlugin(t, second, "gomicrogen-psp-onboard", 0o755)
+	writePlugin(t, second, "gomicrogen-audit", 0o755)
+	writePlugin(t, second, "gomicrogen-readme", 0o644)
+	writePlugin(t, second, "unrelated", 0o755)
+
+	plugins := Finder{Dirs: []string{first}, Path: second}.List()
+
+	if len(plugins) != 3 {
+		t.Fatalf("List = %+v, want 3 plugins", plugins)
+	}
+
+	if plugins[0].Name != "audit" {
+		t.Errorf("plugins must be sorted by name, got %s first", plugins[0].Name)
+	}
+
+	if plugins[1].Name != "psp-onboard" || plugins[1].ShadowedBy != "" || plugins[1].Path != winner {
+		t.Errorf("first psp-onboard = %+v, want the unshadowed winner", plugins[1])
+	}
+	if plugins[2].ShadowedBy != winner {
+		t.Errorf("second psp-onboard = %+v, want it shadowed by %s", plugins[2], winner)
+	}
+}
+
+func TestContextEnvOmitsUnknownValues(t *testing.T) {
+
+	env := Context{TemplatesDir: "/opt/templates", Version: "v1.4.0"}.Env()
+
+	joined := strings.Join(env, "\n")
+
+	for _, want := range []string{EnvTemplatesDir + "=/opt/templates", EnvVersion + "=v1.4.0"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("env missing %s", want)
+		}
+	}
+	if strings.Contains(joined, EnvManifest+"=") {
+		t.Error("outside a service the manifest must not be exported")
+	}
+}
+
+func TestRunReturnsThePluginExitCode(t *testing.T) {
+	skipOnWindows(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gomicrogen-fail")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nexit 3\n"), 0o755); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	code, err := Run(path, nil, Context{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+}
@@ -0,0 +1,82 @@
+package protobuf
+
+import (
+	"path"
+)
+
+// FakeClient writes an in-memory implementation of svc's generated client
+// interface next to proto, for the service's own tests, and returns the file
+// written relative to serviceDir.
+func FakeClient(serviceDir, proto string, file File, svc Service) (string, error) {
+
+	data := handlerData{
+		Proto:   proto,
+		Package: file.GoPackageName(),
+		Name:    svc.Name,
+	}
+
+	data.Methods, data.Skipped = unaryMethods(file, svc)
+
+	rel := path.Join(path.Dir(proto), "fake_"+SnakeCase(svc.Name)+"_client.go")
+
+	return rel, render(serviceDir, rel, fakeClientTemplate, data)
+}
+
+const fakeClientTemplate = `package {{ .Package }}
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// Fake{{ .Name }}Client is an in-memory {{ .Name }}Client for tests, written by
+// gomicrogen from {{ .Proto }}. Set the Func field of each rpc a test
+// exercises; an rpc without one returns an empty response.
+{{- if .Skipped }}
+//
+// Not faked, so calling them panics on the nil embedded client:
+{{- range .Skipped }} {{ . }}{{ end }}.
+{{- end }}
+type Fake{{ .Name }}Client struct {
+	{{ .Name }}Client
+
+{{ range .Methods }}
+	{{ .Name }}Func func(ctx context.Context, in *{{ .Request }}) (*{{ .Response }}, error)
+{{- end }}
+
+	mu    sync.Mutex
+	calls []string
+}
+
+var _ {{ .Name }}Client = (*Fake{{ .Name }}Client)(nil)
+
+// Calls lists the rpcs made so far, in order.
+func (f *Fake{{ .Name }}Client) Calls() []string {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.calls...)
+}
+
+func (f *Fake{{ .Name }}Client) record(method string) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, method)
+}
+{{ range .Methods }}
+func (f *Fake{{ $.Name }}Client) {{ .Name }}(ctx context.Context, in *{{ .Request }}, opts ...grpc.CallOption) (*{{ .Response }}, error) {
+
+	f.record("{{ .Name }}")
+
+	if f.{{ .Name }}Func != nil {
+		return f.{{ .Name }}Func(ctx, in)
+	}
+
+	return &{{ .Response }}{}, nil
+}
+{{ end }}`
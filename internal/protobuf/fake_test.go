@@ -0,0 +1,45 @@
+package protobuf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFakeClientStubsUnaryRPCs(t *testing.T) {
+
+	dir := t.TempDir()
+	proto := "app/grpc/wallet/wallet-service.proto"
+
+	file, err := Parse(walletProto)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	rel, err := FakeClient(dir, proto, file, file.Services[0])
+	if err != nil {
+		t.Fatalf("FakeClient: %v", err)
+	}
+	if rel != "app/grpc/wallet/fake_wallet_client.go" {
+		t.Fatalf("FakeClient wrote %s", rel)
+	}
+
+	fake := read(t, dir, rel)
+
+	for _, want := range []string{
+		"package wallet",
+		"type FakeWalletClient struct {\n\tWalletClient",
+		"PingFunc    func(ctx context.Context, in *WalletPing) (*WalletPong, error)",
+		"var _ WalletClient = (*FakeWalletClient)(nil)",
+		"func (f *FakeWalletClient) Deposit(ctx context.Context, in *DepositRequest, opts ...grpc.CallOption) (*DepositResponse, error) {",
+		"return &DepositResponse{}, nil",
+		"Not faked, so calling them panics on the nil embedded client: Watch Health.",
+	} {
+		if !strings.Contains(fake, want) {
+			t.Errorf("fake client missing %q:\n%s", want, fake)
+		}
+	}
+
+	if strings.Contains(fake, "WatchFunc") {
+		t.Error("streaming rpcs must not be faked")
+	}
+}
@@ -0,0 +1,327 @@
+package protobuf
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// File is what gomicrogen needs from a .proto: its package, where its Go code
+// lives, and the services it declares.
+type File struct {
+	Package   string
+	GoPackage string
+	Services  []Service
+}
+
+// Service is one `service` block.
+type Service struct {
+	Name    string
+	Methods []Method
+}
+
+// Method is one rpc.
+type Method struct {
+	Name            string
+	Request         string
+	Response        string
+	ClientStreaming bool
+	ServerStreaming bool
+}
+
+// Streaming reports whether either side of the rpc is a stream.
+func (m Method) Streaming() bool {
+
+	return m.ClientStreaming || m.ServerStreaming
+}
+
+// GoImportPath is the go_package path, without any ";name" suffix.
+func (f File) GoImportPath() string {
+
+	importPath, _, _ := strings.Cut(f.GoPackage, ";")
+
+	return importPath
+}
+
+// GoPackageName is the Go package name protoc-gen-go will use: the ";name"
+// suffix of go_package when present, otherwise its last path element.
+func (f File) GoPackageName() string {
+
+	if _, name, ok := strings.Cut(f.GoPackage, ";"); ok {
+		return name
+	}
+
+	name := path.Base(f.GoImportPath())
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// LocalType returns a message name as a Go identifier in the file's own
+// package, and false for a type from another package, which the skeleton
+// cannot name without an import.
+func (f File) LocalType(name string) (string, bool) {
+
+	name = strings.TrimPrefix(name, ".")
+
+	if f.Package != "" {
+		name = strings.TrimPrefix(name, f.Package+".")
+	}
+
+	if strings.Contains(name, ".") {
+		return "", false
+	}
+
+	return name, true
+}
+
+// Parse reads the package, go_package option and service definitions from a
+// .proto source. Messages, enums and rpc options are skipped; only enough of
+// the grammar is understood to find services reliably.
+func Parse(src string) (File, error) {
+
+	p := &parser{tokens: tokenize(src)}
+
+	var f File
+
+	for !p.done() {
+
+		switch tok := p.next(); tok {
+
+		case "package":
+			f.Package = p.next()
+			p.skipPast(";")
+
+		case "option":
+			if p.peek() == "go_package" {
+				p.next()
+				if p.next() == "=" {
+					f.GoPackage = unquote(p.next())
+				}
+			}
+			p.skipPast(";")
+
+		case "service":
+			svc, err := p.service()
+			if err != nil {
+				return f, err
+			}
+			f.Services = append(f.Services, svc)
+
+		case "{":
+			p.skipBlock()
+		}
+	}
+
+	return f, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *parser) peek() string {
+
+	if p.done() {
+		return ""
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() string {
+
+	tok := p.peek()
+	p.pos++
+
+	return tok
+}
+
+func (p *parser) expect(want string) error {
+
+	if got := p.next(); got != want {
+		return fmt.Errorf("expected %q, found %q", want, got)
+	}
+
+	return nil
+}
+
+func (p *parser) skipPast(tok string) {
+
+	for !p.done() && p.next() != tok {
+	}
+}
+
+// skipBlock skips to the brace matching one just consumed.
+func (p *parser) skipBlock() {
+
+	for depth := 1; depth > 0 && !p.done(); {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+	}
+}
+
+func (p *parser) service() (Service, error) {
+
+	svc := Service{Name: p.next()}
+
+	if err := p.expect("{"); err != nil {
+		return svc, fmt.Errorf("service %s: %w", svc.Name, err)
+	}
+
+	for !p.done() {
+
+		switch tok := p.next(); tok {
+
+		case "}":
+			return svc, nil
+
+		case "rpc":
+			m, err := p.method()
+			if err != nil {
+				return svc, fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+			svc.Methods = append(svc.Methods, m)
+
+		case "{":
+			p.skipBlock()
+
+		case "option":
+			p.skipPast(";")
+		}
+	}
+
+	return svc, fmt.Errorf("service %s is not closed", svc.Name)
+}
+
+func (p *parser) method() (Method, error) {
+
+	m := Method{Name: p.next()}
+
+	var err error
+
+	if m.Request, m.ClientStreaming, err = p.messageType(); err != nil {
+		return m, fmt.Errorf("rpc %s: %w", m.Name, err)
+	}
+
+	if err := p.expect("returns"); err != nil {
+		return m, fmt.Errorf("rpc %s: %w", m.Name, err)
+	}
+
+	if m.Response, m.ServerStreaming, err = p.messageType(); err != nil {
+		return m, fmt.Errorf("rpc %s: %w", m.Name, err)
+	}
+
+	switch p.next() {
+	case ";":
+	case "{":
+		p.skipBlock()
+	default:
+		return m, fmt.Errorf("rpc %s: expected ; or {", m.Name)
+	}
+
+	return m, nil
+}
+
+// messageType reads `( [stream] Type )`.
+func (p *parser) messageType() (string, bool, error) {
+
+	if err := p.expect("("); err != nil {
+		return "", false, err
+	}
+
+	name, stream := p.next(), false
+
+	if name == "stream" && p.peek() != ")" {
+		name, stream = p.next(), true
+	}
+
+	if err := p.expect(")"); err != nil {
+		return "", false, err
+	}
+
+	return name, stream, nil
+}
+
+// tokenize splits proto source into identifiers (dotted names included),
+// quoted strings and single punctuation characters, dropping comments.
+func tokenize(src string) []string {
+
+	var tokens []string
+
+	for i := 0; i < len(src); {
+
+		c := src[i]
+
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return append(tokens, src[i:])
+			}
+			tokens = append(tokens, src[i:j+1])
+			i = j + 1
+
+		case isWordByte(c):
+			j := i
+			for j < len(src) && isWordByte(src[j]) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+
+	return tokens
+}
+
+func isWordByte(c byte) bool {
+
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func unquote(s string) string {
+
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
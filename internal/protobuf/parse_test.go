@@ -0,0 +1,121 @@
+package protobuf
+
+import (
+	"slices"
+	"testing"
+)
+
+const walletProto = `syntax = "proto3";
+option go_package = "github.com/test-org/svc/app/grpc/wallet";
+package protobuf;
+
+// Wallet owns the player balance.
+service Wallet {
+  rpc Ping (WalletPing) returns (WalletPong) {}
+  /* deposits */
+  rpc Deposit(DepositRequest) returns (DepositResponse);
+  rpc Watch (stream WatchRequest) returns (stream .protobuf.WatchEvent) {
+    option deprecated = true;
+  }
+  rpc Health (google.protobuf.Empty) returns (WalletPong) {}
+}
+
+message WalletPing {}
+
+message DepositRequest {
+  string service = 1; // a field named like a keyword
+  message Nested { int32 rpc = 1; }
+}
+
+service Ledger {
+	rpc Entries(stream EntriesRequest) returns (EntriesResponse) {}
+}
+`
+
+func TestParseFindsServicesAndMethods(t *testing.T) {
+
+	f, err := Parse(walletProto)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if f.Package != "protobuf" || f.GoPackage != "github.com/test-org/svc/app/grpc/wallet" {
+		t.Errorf("file = %+v", f)
+	}
+
+	if len(f.Services) != 2 || f.Services[0].Name != "Wallet" || f.Services[1].Name != "Ledger" {
+		t.Fatalf("services = %+v", f.Services)
+	}
+
+	var names []string
+	for _, m := range f.Services[0].Methods {
+		names = append(names, m.Name)
+	}
+	if !slices.Equal(names, []string{"Ping", "Deposit", "Watch", "Health"}) {
+		t.Errorf("methods = %v", names)
+	}
+
+	deposit := f.Services[0].Methods[1]
+	if deposit.Request != "DepositRequest" || deposit.Response != "DepositResponse" || deposit.Streaming() {
+		t.Errorf("Deposit = %+v", deposit)
+	}
+
+	watch := f.Services[0].Methods[2]
+	if !watch.ClientStreaming || !watch.ServerStreaming || watch.Response != ".protobuf.WatchEvent" {
+		t.Errorf("Watch = %+v", watch)
+	}
+
+	if entries := f.Services[1].Methods[0]; !entries.ClientStreaming || entries.ServerStreaming {
+		t.Errorf("Entries = %+v", entries)
+	}
+}
+
+func TestParseRejectsMalformedRPC(t *testing.T) {
+
+	for _, src := range []string{
+		`service S { rpc M(Req) (Resp) {} }`,
+		`service S { rpc M Req returns (Resp); }`,
+		`service S { rpc M(Req) returns (Resp); `,
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) must fail", src)
+		}
+	}
+}
+
+func TestGoPackageName(t *testing.T) {
+
+	cases := map[string]string{
+		"github.com/test-org/svc/app/grpc/wallet":        "wallet",
+		"github.com/test-org/svc/app/grpc/casino;casino": "casino",
+		"example.com/svc/app/grpc/casino-v2":             "casino_v2",
+	}
+
+	for goPackage, want := range cases {
+		if got := (File{GoPackage: goPackage}).GoPackageName(); got != want {
+			t.Errorf("GoPackageName(%q) = %q, want %q", goPackage, got, want)
+		}
+	}
+}
+
+func TestLocalType(t *testing.T) {
+
+	f := File{Package: "protobuf"}
+
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"DepositRequest", "DepositRequest", true},
+		{".protobuf.DepositRequest", "DepositRequest", true},
+		{"protobuf.DepositRequest", "DepositRequest", true},
+		{"google.protobuf.Empty", "", false},
+	}
+
+	for _, tc := range cases {
+		if got, ok := f.LocalType(tc.in); got != tc.want || ok != tc.ok {
+			t.Errorf("LocalType(%q) = %q, %v, want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
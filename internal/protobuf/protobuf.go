@@ -0,0 +1,260 @@
+package protobuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Plugins are the protoc plugins a service's .proto files are compiled with,
+// and how to install each.
+var Plugins = []Plugin{
+	{Name: "protoc-gen-go", Install: "go install google.golang.org/protobuf/cmd/protoc-gen-go@latest"},
+	{Name: "protoc-gen-go-grpc", Install: "go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@latest"},
+}
+
+// Plugin is a protoc plugin executable.
+type Plugin struct {
+	Name    string
+	Install string
+}
+
+// BufGenConfig is the buf configuration generated services carry. When it is
+// present and buf is installed, buf is preferred over protoc.
+const BufGenConfig = "buf.gen.yaml"
+
+// Compilers gomicrogen knows how to drive.
+const (
+	Buf    = "buf"
+	Protoc = "protoc"
+)
+
+// Toolchain is a compiler plus the plugins it runs.
+type Toolchain struct {
+	Compiler string
+	Path     string
+
+	// Plugins maps each plugin name to its executable.
+	Plugins map[string]string
+}
+
+// Locator finds executables in Dirs, in order.
+type Locator struct {
+	Dirs []string
+}
+
+// NewLocator searches PATH, then where `go install` puts binaries: GOBIN, each
+// GOPATH's bin, and ~/go/bin. The plugins are usually installed with go install
+// and that directory is often missing from PATH.
+func NewLocator() Locator {
+
+	dirs := filepath.SplitList(os.Getenv("PATH"))
+
+	if gobin := os.Getenv("GOBIN"); gobin != "" {
+		dirs = append(dirs, gobin)
+	}
+
+	for _, gopath := range filepath.SplitList(os.Getenv("GOPATH")) {
+		if gopath != "" {
+			dirs = append(dirs, filepath.Join(gopath, "bin"))
+		}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "go", "bin"))
+	}
+
+	return Locator{Dirs: dirs}
+}
+
+// Find returns the first executable called name.
+func (l Locator) Find(name string) (string, bool) {
+
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	for _, dir := range l.Dirs {
+
+		if dir == "" {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		if runtime.GOOS == "windows" || info.Mode().Perm()&0o111 != 0 {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// Toolchain picks how to compile the protos in serviceDir: buf when the
+// service carries a buf.gen.yaml and buf is installed, protoc otherwise. The
+// error names every missing tool with how to install it.
+func (l Locator) Toolchain(serviceDir string) (Toolchain, error) {
+
+	tc := Toolchain{Plugins: map[string]string{}}
+
+	var missing []string
+
+	for _, plugin := range Plugins {
+
+		path, ok := l.Find(plugin.Name)
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%s (%s)", plugin.Name, plugin.Install))
+			continue
+		}
+
+		tc.Plugins[plugin.Name] = path
+	}
+
+	if path, ok := l.Find(Buf); ok && exists(filepath.Join(serviceDir, BufGenConfig)) {
+		tc.Compiler, tc.Path = Buf, path
+	} else if path, ok := l.Find(Protoc); ok {
+		tc.Compiler, tc.Path = Protoc, path
+	} else {
+		missing = append([]string{"protoc or buf (https://grpc.io/docs/protoc-installation/)"}, missing...)
+	}
+
+	if len(missing) > 0 {
+		return tc, fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+
+	return tc, nil
+}
+
+// Files lists the .proto files under serviceDir, relative to it and sorted.
+// vendor/ is skipped.
+func Files(serviceDir string) ([]string, error) {
+
+	var files []string
+
+	err := filepath.WalkDir(serviceDir, func(path string, d fs.DirEntry, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+
+			if path != serviceDir && (d.Name() == "vendor" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if filepath.Ext(path) != ".proto" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(serviceDir, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, filepath.ToSlash(rel))
+
+		return nil
+	})
+
+	sort.Strings(files)
+
+	return files, err
+}
+
+// Args returns the compiler's arguments. Output lands next to each .proto
+// (paths=source_relative), matching the Makefile's proto target.
+func (tc Toolchain) Args(files []string) []string {
+
+	if tc.Compiler == Buf {
+		return []string{"generate"}
+	}
+
+	args := []string{"-I", "."}
+
+	for _, plugin := range Plugins {
+		if path, ok := tc.Plugins[plugin.Name]; ok {
+			args = append(args, "--plugin="+plugin.Name+"="+path)
+		}
+	}
+
+	args = append(args,
+		"--go_out=.", "--go_opt=paths=source_relative",
+		"--go-grpc_out=.", "--go-grpc_opt=paths=source_relative",
+	)
+
+	return append(args, files...)
+}
+
+// Compile runs the toolchain over files inside serviceDir and returns the
+// compiler's combined output. buf finds the plugins on PATH, so their
+// directories are put first on it.
+func Compile(ctx context.Context, serviceDir string, tc Toolchain, files []string) ([]byte, error) {
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	cmd := exec.CommandContext(ctx, tc.Path, tc.Args(files)...)
+	cmd.Dir = serviceDir
+	cmd.Env = append(os.Environ(), "PATH="+tc.searchPath())
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("%s failed: %w", tc.Compiler, err)
+	}
+
+	return out, nil
+}
+
+// Generated returns the files compiling proto produces.
+func Generated(proto string) []string {
+
+	base := strings.TrimSuffix(proto, ".proto")
+
+	return []string{base + ".pb.go", base + "_grpc.pb.go"}
+}
+
+func (tc Toolchain) searchPath() string {
+
+	var dirs []string
+
+	seen := map[string]bool{}
+
+	for _, plugin := range Plugins {
+
+		path, ok := tc.Plugins[plugin.Name]
+		if !ok {
+			continue
+		}
+
+		if dir := filepath.Dir(path); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return strings.Join(append(dirs, os.Getenv("PATH")), string(os.PathListSeparator))
+}
+
+func exists(path string) bool {
+
+	_, err := os.Stat(path)
+
+	return !errors.Is(err, fs.ErrNotExist)
+}
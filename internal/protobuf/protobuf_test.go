@@ -0,0 +1,212 @@
+package protobuf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeExecutable(t *testing.T, dir, name, script string) string {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+
+	return path
+}
+
+func skipOnWindows(t *testing.T) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tools are shell scripts with an executable bit")
+	}
+}
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestFilesFindsProtosAndSkipsVendor(t *testing.T) {
+
+	dir := t.TempDir()
+
+	touch(t, filepath.Join(dir, "app", "grpc", "wallet", "wallet-service.proto"))
+	touch(t, filepath.Join(dir, "app", "grpc", "casino", "casino-service.proto"))
+	touch(t, filepath.Join(dir, "app", "grpc", "casino", "casino-service.pb.go"))
+	touch(t, filepath.Join(dir, "vendor", "example.com", "dep", "dep.proto"))
+	touch(t, filepath.Join(dir, ".git", "stray.proto"))
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+
+	want := []string{"app/grpc/casino/casino-service.proto", "app/grpc/wallet/wallet-service.proto"}
+	if !slices.Equal(files, want) {
+		t.Errorf("Files = %v, want %v", files, want)
+	}
+}
+
+func TestToolchainPrefersBufWithItsConfig(t *testing.T) {
+	skipOnWindows(t)
+
+	bin := t.TempDir()
+	for _, name := range []string{"buf", "protoc", "protoc-gen-go", "protoc-gen-go-grpc"} {
+		writeExecutable(t, bin, name, "#!/bin/sh\n")
+	}
+
+	service := t.TempDir()
+	l := Locator{Dirs: []string{bin}}
+
+	tc, err := l.Toolchain(service)
+	if err != nil || tc.Compiler != Protoc {
+		t.Fatalf("without %s: Toolchain = %+v, %v, want protoc", BufGenConfig, tc, err)
+	}
+
+	touch(t, filepath.Join(service, BufGenConfig))
+
+	tc, err = l.Toolchain(service)
+	if err != nil || tc.Compiler != Buf {
+		t.Fatalf("with %s: Toolchain = %+v, %v, want buf", BufGenConfig, tc, err)
+	}
+}
+
+func TestToolchainNamesEveryMissingTool(t *testing.T) {
+	skipOnWindows(t)
+
+	bin := t.TempDir()
+	writeExecutable(t, bin, "protoc-gen-go", "#!/bin/sh\n")
+
+	_, err := Locator{Dirs: []string{bin}}.Toolchain(t.TempDir())
+	if err == nil {
+		t.Fatal("missing tools must be an error")
+	}
+
+	for _, want := range []string{"protoc or buf", "protoc-gen-go-grpc", "go install google.golang.org/grpc/cmd/protoc-gen-go-grpc"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "protoc-gen-go (") {
+		t.Errorf("an installed plugin must not be reported missing: %v", err)
+	}
+}
+
+func TestFindSkipsNonExecutables(t *testing.T) {
+	skipOnWindows(t)
+
+	first, second := t.TempDir(), t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(first, "protoc"), nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	want := writeExecutable(t, second, "protoc", "#!/bin/sh\n")
+
+	if got, ok := (Locator{Dirs: []string{first, second}}).Find("protoc"); !ok || got != want {
+		t.Errorf("Find = %q, %v, want %s", got, ok, want)
+	}
+}
+
+func TestProtocArgsPinThePlugins(t *testing.T) {
+
+	tc := Toolchain{
+		Compiler: Protoc,
+		Plugins:  map[string]string{"protoc-gen-go": "/go/bin/protoc-gen-go", "protoc-gen-go-grpc": "/go/bin/protoc-gen-go-grpc"},
+	}
+
+	args := tc.Args([]string{"app/grpc/wallet/wallet-service.proto"})
+
+	for _, want := range []string{
+		"--plugin=protoc-gen-go=/go/bin/protoc-gen-go",
+		"--plugin=protoc-gen-go-grpc=/go/bin/protoc-gen-go-grpc",
+		"--go_opt=paths=source_relative",
+		"--go-grpc_opt=paths=source_relative",
+	} {
+		if !slices.Contains(args, want) {
+			t.Errorf("args %v missing %s", args, want)
+		}
+	}
+
+	if args[len(args)-1] != "app/grpc/wallet/wallet-service.proto" {
+		t.Errorf("the proto files must come last, got %v", args)
+	}
+
+	if got := (Toolchain{Compiler: Buf}).Args(nil); !slices.Equal(got, []string{"generate"}) {
+		t.Errorf("buf args = %v, want generate", got)
+	}
+}
+
+func TestCompileRunsInTheServiceWithPluginsOnPath(t *testing.T) {
+	skipOnWindows(t)
+
+	bin := t.TempDir()
+
+	// the fake compiler records where it ran, its arguments and whether the
+	// plugins are reachable through PATH, the way buf looks them up
+	compiler := writeExecutable(t, bin, "buf", `#!/bin/sh
+pwd > ran.txt
+echo "$@" >> ran.txt
+command -v protoc-gen-go-grpc >> ran.txt
+`)
+	plugin := writeExecutable(t, t.TempDir(), "protoc-gen-go-grpc", "#!/bin/sh\n")
+
+	service := t.TempDir()
+	tc := Toolchain{Compiler: Buf, Path: compiler, Plugins: map[string]string{"protoc-gen-go-grpc": plugin}}
+
+	if _, err := Compile(context.Background(), service, tc, []string{"app/grpc/wallet/wallet-service.proto"}); err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	ran, err := os.ReadFile(filepath.Join(service, "ran.txt"))
+	if err != nil {
+		t.Fatalf("the compiler must run inside the service: %v", err)
+	}
+
+	for _, want := range []string{"generate", plugin} {
+		if !strings.Contains(string(ran), want) {
+			t.Errorf("compiler saw %q, want it to mention %s", ran, want)
+		}
+	}
+}
+
+func TestCompileReportsFailureWithOutput(t *testing.T) {
+	skipOnWindows(t)
+
+	compiler := writeExecutable(t, t.TempDir(), "protoc", "#!/bin/sh\necho 'wallet-service.proto:3:1: syntax error'\nexit 1\n")
+
+	out, err := Compile(context.Background(), t.TempDir(), Toolchain{Compiler: Protoc, Path: compiler}, []string{"wallet-service.proto"})
+	if err == nil {
+		t.Fatal("a failing compiler must be an error")
+	}
+	if !strings.Contains(string(out), "syntax error") {
+		t.Errorf("output = %q, want the compiler's message", out)
+	}
+}
+
+func TestGeneratedNamesBothOutputs(t *testing.T) {
+
+	got := Generated("app/grpc/casino/casino-service.proto")
+	want := []string{"app/grpc/casino/casino-service.pb.go", "app/grpc/casino/casino-service_grpc.pb.go"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("Generated = %v, want %v", got, want)
+	}
+}
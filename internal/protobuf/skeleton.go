@@ -0,0 +1,321 @@
+package protobuf
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// RegisterFile is where the generated registration of every handler lands.
+const RegisterFile = "app/router/grpc.go"
+
+// Skeletons writes a handler, and a table-driven test for it, next to every
+// service declared in the service's .proto files, plus app/router/grpc.go
+// registering them all. It returns the files written, relative to serviceDir.
+// Nothing is written when no .proto declares a service.
+//
+// The handlers only compile against the .pb.go protoc generates, so they are
+// written whether or not compilation ran; make proto completes them.
+func Skeletons(serviceDir, serviceName, moduleName string, protos []string) ([]string, error) {
+
+	var (
+		written       []string
+		registrations []registration
+	)
+
+	for _, proto := range protos {
+
+		src, err := os.ReadFile(filepath.Join(serviceDir, filepath.FromSlash(proto)))
+		if err != nil {
+			return written, err
+		}
+
+		file, err := Parse(string(src))
+		if err != nil {
+			return written, fmt.Errorf("%s: %w", proto, err)
+		}
+
+		if len(file.Services) == 0 {
+			continue
+		}
+
+		dir := path.Dir(proto)
+
+		importPath := file.GoImportPath()
+		if importPath == "" {
+			importPath = moduleName + "/" + dir
+			file.GoPackage = importPath
+		}
+
+		pkg := file.GoPackageName()
+
+		for _, svc := range file.Services {
+
+			data := handlerData{
+				Proto:       proto,
+				Package:     pkg,
+				Name:        svc.Name,
+				ServiceName: serviceName,
+				ModuleName:  moduleName,
+			}
+
+			data.Methods, data.Skipped = unaryMethods(file, svc)
+
+			base := path.Join(dir, SnakeCase(svc.Name)+"_handler")
+
+			if err := render(serviceDir, base+".go", handlerTemplate, data); err != nil {
+				return written, err
+			}
+			if err := render(serviceDir, base+"_test.go", handlerTestTemplate, data); err != nil {
+				return written, err
+			}
+
+			written = append(written, base+".go", base+"_test.go")
+
+			registrations = append(registrations, registration{Import: importPath, Package: pkg, Name: svc.Name})
+		}
+	}
+
+	if len(registrations) == 0 {
+		return written, nil
+	}
+
+	imports := map[string]bool{}
+	for _, r := range registrations {
+		imports[r.Import] = true
+	}
+
+	data := registerData{Registrations: registrations}
+	for imp := range imports {
+		data.Imports = append(data.Imports, imp)
+	}
+	sort.Strings(data.Imports)
+
+	if err := render(serviceDir, RegisterFile, registerTemplate, data); err != nil {
+		return written, err
+	}
+
+	return append(written, RegisterFile), nil
+}
+
+type handlerData struct {
+	Proto       string
+	Package     string
+	Name        string
+	ServiceName string
+	ModuleName  string
+	Methods     []Method
+	Skipped     []string
+}
+
+type registration struct {
+	Import  string
+	Package string
+	Name    string
+}
+
+type registerData struct {
+	Imports       []string
+	Registrations []registration
+}
+
+// unaryMethods splits svc's rpcs into those a stub can be written for, with
+// message names resolved to Go identifiers, and the names of the rest:
+// streaming rpcs, whose signatures depend on the protoc-gen-go-grpc version,
+// and rpcs using another package's messages.
+func unaryMethods(file File, svc Service) ([]Method, []string) {
+
+	var (
+		methods []Method
+		skipped []string
+	)
+
+	for _, m := range svc.Methods {
+
+		req, reqOK := file.LocalType(m.Request)
+		resp, respOK := file.LocalType(m.Response)
+
+		if m.Streaming() || !reqOK || !respOK {
+			skipped = append(skipped, m.Name)
+			continue
+		}
+
+		methods = append(methods, Method{Name: m.Name, Request: req, Response: resp})
+	}
+
+	return methods, skipped
+}
+
+// render executes a Go source template into serviceDir/rel and gofmts it.
+func render(serviceDir, rel, text string, data any) error {
+
+	tmpl, err := template.New(rel).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return fmt.Errorf("render %s: %w", rel, err)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("format %s: %w", rel, err)
+	}
+
+	target := filepath.Join(serviceDir, filepath.FromSlash(rel))
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(target, src, 0o644)
+}
+
+// SnakeCase turns a service name such as CasinoBonus into casino_bonus.
+func SnakeCase(name string) string {
+
+	var b strings.Builder
+
+	runes := []rune(name)
+
+	for i, r := range runes {
+
+		if unicode.IsUpper(r) {
+
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+
+			r = unicode.ToLower(r)
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+const handlerTemplate = `package {{ .Package }}
+
+import (
+	"context"
+	"database/sql"
+
+	"{{ .ModuleName }}/app/apperr"
+	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// {{ .Name }}Handler serves the {{ .Name }} service declared in {{ .Proto }}.
+// gomicrogen wrote one stub per rpc; each starts a span and answers
+// apperr.Unimplemented until it is filled in. Return apperr errors here as the
+// HTTP handlers do, so both speak the same vocabulary. make proto never
+// touches this file.
+{{- if .Skipped }}
+//
+// Not stubbed, so served by the embedded Unimplemented{{ .Name }}Server:
+{{- range .Skipped }} {{ . }}{{ end }}.
+{{- end }}
+type {{ .Name }}Handler struct {
+	Unimplemented{{ .Name }}Server
+
+	DB        *sql.DB
+	RedisConn *redis.Client
+
+	// Tracer defaults to the global tracer
+	Tracer trace.Tracer
+}
+
+func (h *{{ .Name }}Handler) tracer() trace.Tracer {
+
+	if h.Tracer != nil {
+		return h.Tracer
+	}
+
+	return otel.Tracer("{{ .ServiceName }}")
+}
+{{ range .Methods }}
+// {{ .Name }} handles {{ $.Name }}.{{ .Name }}.
+func (h *{{ $.Name }}Handler) {{ .Name }}(ctx context.Context, req *{{ .Request }}) (*{{ .Response }}, error) {
+
+	_, span := h.tracer().Start(ctx, "{{ $.Name }}.{{ .Name }}")
+	defer span.End()
+
+	return nil, apperr.Unimplemented("{{ $.Name }}.{{ .Name }} is not implemented")
+}
+{{ end }}`
+
+const handlerTestTemplate = `package {{ .Package }}
+
+import (
+	"context"
+	"testing"
+
+	"{{ .ModuleName }}/app/apperr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Every rpc starts out unimplemented. As one is filled in, change its row to
+// what it now returns and add rows for the requests that matter.
+func Test{{ .Name }}Handler(t *testing.T) {
+
+	h := &{{ .Name }}Handler{}
+	ctx := context.Background()
+
+	cases := []struct {
+		name string
+		call func() error
+		code codes.Code
+		kind apperr.Kind
+	}{
+{{- range .Methods }}
+		{"{{ .Name }}", func() error { _, err := h.{{ .Name }}(ctx, &{{ .Request }}{}); return err }, codes.Unimplemented, apperr.KindUnimplemented},
+{{- end }}
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			err := tc.call()
+
+			if code := status.Code(err); code != tc.code {
+				t.Fatalf("code = %s, want %s", code, tc.code)
+			}
+
+			if tc.kind != "" && apperr.From(err).Kind != tc.kind {
+				t.Errorf("kind = %s, want %s", apperr.From(err).Kind, tc.kind)
+			}
+		})
+	}
+}
+`
+
+const registerTemplate = `package router
+
+import (
+{{- range .Imports }}
+	"{{ . }}"
+{{- end }}
+	"google.golang.org/grpc"
+)
+
+// registerGRPCServers registers a handler for every service in the service's
+// .proto files. gomicrogen wrote it from those files; add a line here when a
+// proto gains a service.
+func (a *App) registerGRPCServers(s *grpc.Server) {
+{{ range .Registrations }}
+	{{ .Package }}.Register{{ .Name }}Server(s, &{{ .Package }}.{{ .Name }}Handler{DB: a.DB, RedisConn: a.RedisConn})
+{{- end }}
+}
+`
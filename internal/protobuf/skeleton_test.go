@@ -0,0 +1,127 @@
+package protobuf
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeProto(t *testing.T, serviceDir, rel, src string) {
+	t.Helper()
+
+	path := filepath.Join(serviceDir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func read(t *testing.T, serviceDir, rel string) string {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join(serviceDir, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatalf("read %s: %v", rel, err)
+	}
+
+	return string(body)
+}
+
+func TestSkeletonsWriteHandlersTestsAndRegistration(t *testing.T) {
+
+	dir := t.TempDir()
+	writeProto(t, dir, "app/grpc/wallet/wallet-service.proto", walletProto)
+
+	written, err := Skeletons(dir, "svc", "github.com/test-org/svc", []string{"app/grpc/wallet/wallet-service.proto"})
+	if err != nil {
+		t.Fatalf("Skeletons: %v", err)
+	}
+
+	want := []string{
+		"app/grpc/wallet/wallet_handler.go",
+		"app/grpc/wallet/wallet_handler_test.go",
+		"app/grpc/wallet/ledger_handler.go",
+		"app/grpc/wallet/ledger_handler_test.go",
+		RegisterFile,
+	}
+	if !slices.Equal(written, want) {
+		t.Fatalf("written = %v, want %v", written, want)
+	}
+
+	handler := read(t, dir, "app/grpc/wallet/wallet_handler.go")
+
+	for _, want := range []string{
+		"package wallet",
+		"type WalletHandler struct {\n\tUnimplementedWalletServer",
+		"func (h *WalletHandler) Deposit(ctx context.Context, req *DepositRequest) (*DepositResponse, error)",
+		`h.tracer().Start(ctx, "Wallet.Deposit")`,
+		`"github.com/test-org/svc/app/apperr"`,
+		`return nil, apperr.Unimplemented("Wallet.Deposit is not implemented")`,
+		`otel.Tracer("svc")`,
+		"served by the embedded UnimplementedWalletServer: Watch Health.",
+	} {
+		if !strings.Contains(handler, want) {
+			t.Errorf("handler missing %q:\n%s", want, handler)
+		}
+	}
+
+	if strings.Contains(handler, "func (h *WalletHandler) Watch") || strings.Contains(handler, "func (h *WalletHandler) Health") {
+		t.Error("streaming rpcs and foreign message types must be left to the Unimplemented server")
+	}
+
+	test := read(t, dir, "app/grpc/wallet/wallet_handler_test.go")
+	for _, want := range []string{"func TestWalletHandler(t *testing.T)", `{"Ping", func() error { _, err := h.Ping(ctx, &WalletPing{}); return err }, codes.Unimplemented, apperr.KindUnimplemented}`} {
+		if !strings.Contains(test, want) {
+			t.Errorf("test missing %q:\n%s", want, test)
+		}
+	}
+
+	register := read(t, dir, RegisterFile)
+	for _, want := range []string{
+		`"github.com/test-org/svc/app/grpc/wallet"`,
+		"wallet.RegisterWalletServer(s, &wallet.WalletHandler{DB: a.DB, RedisConn: a.RedisConn})",
+		"wallet.RegisterLedgerServer(s, &wallet.LedgerHandler{DB: a.DB, RedisConn: a.RedisConn})",
+	} {
+		if !strings.Contains(register, want) {
+			t.Errorf("registration missing %q:\n%s", want, register)
+		}
+	}
+}
+
+func TestSkeletonsDefaultTheImportPathFromTheModule(t *testing.T) {
+
+	dir := t.TempDir()
+	writeProto(t, dir, "app/grpc/bonus/bonus.proto", `syntax = "proto3"; service Bonus { rpc Grant(GrantRequest) returns (GrantResponse); }`)
+
+	if _, err := Skeletons(dir, "svc", "github.com/test-org/svc", []string{"app/grpc/bonus/bonus.proto"}); err != nil {
+		t.Fatalf("Skeletons: %v", err)
+	}
+
+	if register := read(t, dir, RegisterFile); !strings.Contains(register, `"github.com/test-org/svc/app/grpc/bonus"`) {
+		t.Errorf("without go_package the import must follow the module and directory:\n%s", register)
+	}
+}
+
+func TestSkeletonsWithoutServicesWriteNothing(t *testing.T) {
+
+	dir := t.TempDir()
+	writeProto(t, dir, "app/grpc/types/types.proto", `syntax = "proto3"; message Money { int64 cents = 1; }`)
+
+	written, err := Skeletons(dir, "svc", "github.com/test-org/svc", []string{"app/grpc/types/types.proto"})
+	if err != nil || len(written) != 0 {
+		t.Errorf("Skeletons = %v, %v, want nothing written", written, err)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+
+	for in, want := range map[string]string{"Casino": "casino", "CasinoBonus": "casino_bonus", "PSPGateway": "psp_gateway"} {
+		if got := SnakeCase(in); got != want {
+			t.Errorf("SnakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
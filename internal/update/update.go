@@ -0,0 +1,460 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Choplife-group/gomicrogen/internal/version"
+)
+
+const (
+	// Repo is where releases are published.
+	Repo = "surahj/gomicrogen"
+
+	// BinaryName prefixes every release archive.
+	BinaryName = "gomicrogen"
+
+	// ChecksumsFile is published beside the archives by `make release`, in
+	// sha256sum format.
+	ChecksumsFile = "checksums.txt"
+
+	// BaseURLEnv overrides where archives are fetched from. It is the same
+	// variable the installers honour, so a mirror set up for them works here.
+	BaseURLEnv = "GOMICROGEN_BASE_URL"
+)
+
+// DefaultLatestURL reports the newest release. Prereleases never become
+// "latest", which matches what the installers resolve.
+var DefaultLatestURL = "https://api.github.com/repos/" + Repo + "/releases/latest"
+
+// Options controls a self-update.
+type Options struct {
+	// Version is the release to install; empty means the latest release.
+	Version string
+
+	// CurrentVersion is the running binary's version, used to refuse
+	// downgrades. A dev build has no comparable version and is always updated.
+	CurrentVersion string
+
+	// BaseURL is the directory holding the archives and checksums. Empty means
+	// the GitHub release download directory for Version.
+	BaseURL string
+
+	// LatestURL resolves the latest version when Version is empty.
+	LatestURL string
+
+	// ExecPath is the binary to replace; templates/ beside it is replaced too.
+	ExecPath string
+
+	OS   string
+	Arch string
+
+	// Force allows reinstalling the same version or downgrading.
+	Force bool
+
+	Client *http.Client
+}
+
+// Result describes what Run did.
+type Result struct {
+	Version  string
+	Archive  string
+	Updated  bool
+	Previous string
+}
+
+// ArchiveName is the release archive for a platform, as built by `make release`.
+func ArchiveName(goos, goarch string) string {
+
+	if goos == "windows" {
+		return fmt.Sprintf("%s-%s-%s.zip", BinaryName, goos, goarch)
+	}
+
+	return fmt.Sprintf("%s-%s-%s.tar.gz", BinaryName, goos, goarch)
+}
+
+// packageDir is the single top-level directory inside each archive.
+func packageDir(goos, goarch string) string {
+
+	return fmt.Sprintf("%s-%s-%s-package", BinaryName, goos, goarch)
+}
+
+// packageBinary is the binary's name inside the package directory.
+func packageBinary(goos, goarch string) string {
+
+	if goos == "windows" {
+		return fmt.Sprintf("%s-%s-%s.exe", BinaryName, goos, goarch)
+	}
+
+	return fmt.Sprintf("%s-%s-%s", BinaryName, goos, goarch)
+}
+
+// Run downloads the release, verifies it against the checksums file and
+// replaces the binary and its templates directory.
+func Run(opts Options) (Result, error) {
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+
+	result := Result{Previous: opts.CurrentVersion}
+
+	target := strings.TrimSpace(opts.Version)
+	if target == "" {
+
+		latestURL := opts.LatestURL
+		if latestURL == "" {
+			latestURL = DefaultLatestURL
+		}
+
+		latest, err := resolveLatest(client, latestURL)
+		if err != nil {
+			return result, err
+		}
+
+		target = latest
+	}
+
+	result.Version = target
+
+	if err := checkDirection(opts.CurrentVersion, target, opts.Force); err != nil {
+		return result, err
+	}
+
+	baseURL := strings.TrimSuffix(opts.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "https://github.com/" + Repo + "/releases/download/" + target
+	}
+
+	archive := ArchiveName(opts.OS, opts.Arch)
+	result.Archive = baseURL + "/" + archive
+
+	sums, err := fetch(client, baseURL+"/"+ChecksumsFile)
+	if err != nil {
+		return result, fmt.Errorf("failed to download %s: %w; releases published before checksums cannot be verified, use the installer instead", ChecksumsFile, err)
+	}
+
+	want, err := lookupChecksum(sums, archive)
+	if err != nil {
+		return result, err
+	}
+
+	body, err := fetch(client, result.Archive)
+	if err != nil {
+		return result, fmt.Errorf("failed to download %s: %w", archive, err)
+	}
+
+	sum := sha256.Sum256(body)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return result, fmt.Errorf("checksum mismatch for %s: got %s, want %s", archive, got, want)
+	}
+
+	execPath, err := filepath.EvalSymlinks(opts.ExecPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to resolve %s: %w", opts.ExecPath, err)
+	}
+
+	installDir := filepath.Dir(execPath)
+
+	// Stage beside the install so the final renames stay on one filesystem
+	staging, err := os.MkdirTemp(installDir, ".gomicrogen-update-")
+	if err != nil {
+		return result, fmt.Errorf("failed to create a staging directory in %s: %w", installDir, err)
+	}
+	defer os.RemoveAll(staging)
+
+	if opts.OS == "windows" {
+		err = extractZip(body, staging)
+	} else {
+		err = extractTarGz(body, staging)
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to extract %s: %w", archive, err)
+	}
+
+	pkg := filepath.Join(staging, packageDir(opts.OS, opts.Arch))
+
+	newBinary := filepath.Join(pkg, packageBinary(opts.OS, opts.Arch))
+	if info, err := os.Stat(newBinary); err != nil || info.IsDir() {
+		return result, fmt.Errorf("%s does not contain %s", archive, filepath.Join(packageDir(opts.OS, opts.Arch), packageBinary(opts.OS, opts.Arch)))
+	}
+
+	newTemplates := filepath.Join(pkg, "templates")
+	if info, err := os.Stat(filepath.Join(newTemplates, "base")); err != nil || !info.IsDir() {
+		return result, fmt.Errorf("%s does not contain templates/base", archive)
+	}
+
+	if err := install(execPath, newBinary, newTemplates); err != nil {
+		return result, err
+	}
+
+	result.Updated = true
+
+	return result, nil
+}
+
+// checkDirection refuses reinstalls and downgrades unless forced.
+func checkDirection(current, target string, force bool) error {
+
+	if force || !version.Valid(current) {
+		return nil
+	}
+
+	if !version.Valid(target) {
+		return fmt.Errorf("cannot compare target version %q with the installed %s; pass --force to install it anyway", target, current)
+	}
+
+	switch version.Compare(target, current) {
+	case 0:
+		return fmt.Errorf("%s is already installed; pass --force to reinstall it", current)
+	case -1:
+		return fmt.Errorf("refusing to downgrade from %s to %s; pass --force to downgrade", current, target)
+	}
+
+	return nil
+}
+
+// install swaps the templates directory and then the binary into place. Each
+// swap moves the old copy aside first, so a failure part way restores what was
+// there and a running binary is never truncated.
+func install(execPath, newBinary, newTemplates string) error {
+
+	installDir := filepath.Dir(execPath)
+
+	info, err := os.Stat(execPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", execPath, err)
+	}
+
+	if err := os.Chmod(newBinary, info.Mode().Perm()|0o111); err != nil {
+		return fmt.Errorf("failed to mark the new binary executable: %w", err)
+	}
+
+	templatesDir := filepath.Join(installDir, "templates")
+	oldTemplates := templatesDir + ".old"
+	oldBinary := execPath + ".old"
+
+	// leftovers from an interrupted update
+	os.RemoveAll(oldTemplates)
+	os.Remove(oldBinary)
+
+	hadTemplates := false
+	if _, err := os.Stat(templatesDir); err == nil {
+
+		if err := os.Rename(templatesDir, oldTemplates); err != nil {
+			return fmt.Errorf("failed to move the old templates aside: %w", err)
+		}
+
+		hadTemplates = true
+	}
+
+	restoreTemplates := func() {
+
+		os.RemoveAll(templatesDir)
+		if hadTemplates {
+			os.Rename(oldTemplates, templatesDir)
+		}
+	}
+
+	if err := os.Rename(newTemplates, templatesDir); err != nil {
+		restoreTemplates()
+		return fmt.Errorf("failed to install the new templates: %w", err)
+	}
+
+	if err := os.Rename(execPath, oldBinary); err != nil {
+		restoreTemplates()
+		return fmt.Errorf("failed to move the old binary aside: %w", err)
+	}
+
+	if err := os.Rename(newBinary, execPath); err != nil {
+		os.Rename(oldBinary, execPath)
+		restoreTemplates()
+		return fmt.Errorf("failed to install the new binary: %w", err)
+	}
+
+	os.RemoveAll(oldTemplates)
+
+	// Windows will not delete a running executable; the next update clears it
+	os.Remove(oldBinary)
+
+	return nil
+}
+
+func resolveLatest(client *http.Client, latestURL string) (string, error) {
+
+	body, err := fetch(client, latestURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the latest release: %w; pass --version to pick one", err)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+
+	if err := json.Unmarshal(body, &release); err != nil || release.TagName == "" {
+		return "", fmt.Errorf("failed to resolve the latest release from %s; pass --version to pick one", latestURL)
+	}
+
+	return release.TagName, nil
+}
+
+func fetch(client *http.Client, url string) ([]byte, error) {
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// lookupChecksum finds an archive in sha256sum output. The binary-mode marker
+// ("*name") that some tools emit is accepted.
+func lookupChecksum(sums []byte, archive string) (string, error) {
+
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+
+	for scanner.Scan() {
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		if strings.TrimPrefix(fields[1], "*") == archive {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	return "", fmt.Errorf("%s has no entry for %s", ChecksumsFile, archive)
+}
+
+// safeJoin rejects archive entries that would escape the destination.
+func safeJoin(dest, name string) (string, error) {
+
+	target := filepath.Join(dest, name)
+
+	if target != dest && !strings.HasPrefix(target, dest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %q escapes the extraction directory", name)
+	}
+
+	return target, nil
+}
+
+func extractTarGz(body []byte, dest string) error {
+
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	for {
+
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := safeJoin(dest, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := writeFile(target, tr, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractZip(body []byte, dest string) error {
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+
+		target, err := safeJoin(dest, f.Name)
+		if err != nil {
+			return err
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+
+		err = writeFile(target, rc, f.Mode().Perm())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	if mode == 0 {
+		mode = 0o644
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,402 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// release is a fake release directory served over HTTP.
+type release struct {
+	files map[string][]byte
+}
+
+// packageFiles is what `make release` puts in a package directory.
+func packageFiles(goos, goarch, marker string) map[string]string {
+
+	return map[string]string{
+		packageBinary(goos, goarch):             "#!/bin/sh\necho " + marker + "\n",
+		"templates/base/main.go.tmpl":           "package main // " + marker,
+		"templates/types/general/type.json":     `{"description": "` + marker + `"}`,
+		"templates/types/general/app/router.go": "package router",
+	}
+}
+
+func tarGz(t *testing.T, dir string, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, body := range files {
+
+		mode := int64(0o644)
+		if !strings.Contains(name, "/") {
+			mode = 0o755
+		}
+
+		if err := tw.WriteHeader(&tar.Header{Name: dir + "/" + name, Mode: mode, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("tar write: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, dir string, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, body := range files {
+
+		w, err := zw.Create(dir + "/" + name)
+		if err != nil {
+			t.Fatalf("zip create: %v", err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatalf("zip write: %v", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func newRelease(t *testing.T, goos, goarch, marker string) *release {
+	t.Helper()
+
+	files := packageFiles(goos, goarch, marker)
+
+	var archive []byte
+	if goos == "windows" {
+		archive = zipped(t, packageDir(goos, goarch), files)
+	} else {
+		archive = tarGz(t, packageDir(goos, goarch), files)
+	}
+
+	sum := sha256.Sum256(archive)
+
+	return &release{files: map[string][]byte{
+		ArchiveName(goos, goarch): archive,
+		ChecksumsFile:             []byte(fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), ArchiveName(goos, goarch))),
+	}}
+}
+
+// serve exposes the release under /download/ and a latest-release document
+// naming latestTag.
+func (r *release) serve(t *testing.T, latestTag string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": %q}`, latestTag)
+	})
+
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, req *http.Request) {
+
+		body, ok := r.files[strings.TrimPrefix(req.URL.Path, "/download/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+
+		w.Write(body)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// installation lays out an installed binary with templates beside it.
+func installation(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, BinaryName), []byte("old binary"), 0o755); err != nil {
+		t.Fatalf("write binary: %v", err)
+	}
+
+	stale := filepath.Join(dir, "templates", "base", "stale.tmpl")
+	if err := os.MkdirAll(filepath.Dir(stale), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(stale, []byte("from the old release"), 0o644); err != nil {
+		t.Fatalf("write stale: %v", err)
+	}
+
+	return filepath.Join(dir, BinaryName)
+}
+
+func options(srv *httptest.Server, execPath string) Options {
+
+	return Options{
+		CurrentVersion: "v1.0.0",
+		BaseURL:        srv.URL + "/download",
+		LatestURL:      srv.URL + "/latest",
+		ExecPath:       execPath,
+		OS:             "linux",
+		Arch:           "amd64",
+		Client:         srv.Client(),
+	}
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+
+	return string(body)
+}
+
+func TestUpdateReplacesBinaryAndTemplates(t *testing.T) {
+
+	srv := newRelease(t, "linux", "amd64", "v1.1.0").serve(t, "v1.1.0")
+	execPath := installation(t)
+
+	result, err := Run(options(srv, execPath))
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	if !result.Updated || result.Version != "v1.1.0" {
+		t.Errorf("result = %+v, want an update to v1.1.0", result)
+	}
+
+	if !strings.Contains(read(t, execPath), "v1.1.0") {
+		t.Error("the binary was not replaced")
+	}
+
+	info, err := os.Stat(execPath)
+	if err != nil || info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("the new binary must be executable: %v", info.Mode())
+	}
+
+	dir := filepath.Dir(execPath)
+
+	if !strings.Contains(read(t, filepath.Join(dir, "templates", "base", "main.go.tmpl")), "v1.1.0") {
+		t.Error("the templates were not replaced")
+	}
+
+	// replaced, not merged: a stale file from an older layout must not survive
+	if _, err := os.Stat(filepath.Join(dir, "templates", "base", "stale.tmpl")); err == nil {
+		t.Error("the old templates directory was merged into rather than replaced")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".gomicrogen-update-") || strings.HasSuffix(e.Name(), ".old") {
+			t.Errorf("update left %s behind", e.Name())
+		}
+	}
+}
+
+func TestUpdateToExplicitVersion(t *testing.T) {
+
+	srv := newRelease(t, "linux", "amd64", "v1.2.0").serve(t, "v9.9.9")
+	execPath := installation(t)
+
+	opts := options(srv, execPath)
+	opts.Version = "v1.2.0"
+
+	result, err := Run(opts)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	if result.Version != "v1.2.0" {
+		t.Errorf("--version must win over latest, got %s", result.Version)
+	}
+}
+
+func TestUpdateWindowsZip(t *testing.T) {
+
+	srv := newRelease(t, "windows", "amd64", "v1.1.0").serve(t, "v1.1.0")
+	execPath := installation(t)
+
+	opts := options(srv, execPath)
+	opts.OS = "windows"
+
+	if _, err := Run(opts); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	if !strings.Contains(read(t, execPath), "v1.1.0") {
+		t.Error("the binary was not replaced from the zip")
+	}
+}
+
+func TestChecksumMismatchLeavesInstallUntouched(t *testing.T) {
+
+	rel := newRelease(t, "linux", "amd64", "v1.1.0")
+	rel.files[ChecksumsFile] = []byte(strings.Repeat("0", 64) + "  " + ArchiveName("linux", "amd64") + "\n")
+
+	srv := rel.serve(t, "v1.1.0")
+	execPath := installation(t)
+
+	_, err := Run(options(srv, execPath))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("err = %v, want a checksum mismatch", err)
+	}
+
+	if read(t, execPath) != "old binary" {
+		t.Error("a failed verification must not touch the binary")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(execPath), "templates", "base", "stale.tmpl")); err != nil {
+		t.Error("a failed verification must not touch the templates")
+	}
+}
+
+func TestMissingChecksumsRefuses(t *testing.T) {
+
+	rel := newRelease(t, "linux", "amd64", "v1.1.0")
+	delete(rel.files, ChecksumsFile)
+
+	srv := rel.serve(t, "v1.1.0")
+
+	if _, err := Run(options(srv, installation(t))); err == nil {
+		t.Fatal("an archive that cannot be verified must not be installed")
+	}
+}
+
+func TestChecksumsWithoutThisPlatformRefuses(t *testing.T) {
+
+	rel := newRelease(t, "linux", "amd64", "v1.1.0")
+	rel.files[ChecksumsFile] = []byte(strings.Repeat("a", 64) + "  " + ArchiveName("darwin", "arm64") + "\n")
+
+	srv := rel.serve(t, "v1.1.0")
+
+	_, err := Run(options(srv, installation(t)))
+	if err == nil || !strings.Contains(err.Error(), "no entry") {
+		t.Fatalf("err = %v, want a missing checksum entry", err)
+	}
+}
+
+func TestDowngradeAndReinstallNeedForce(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		current string
+		target  string
+		force   bool
+		wantErr string
+	}{
+		{"downgrade", "v1.2.0", "v1.1.0", false, "downgrade"},
+		{"same version", "v1.1.0", "v1.1.0", false, "already installed"},
+		{"forced downgrade", "v1.2.0", "v1.1.0", true, ""},
+		{"upgrade", "v1.0.0", "v1.1.0", false, ""},
+		// a dev build has nothing to compare against
+		{"dev build", "dev", "v1.1.0", false, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			srv := newRelease(t, "linux", "amd64", tc.target).serve(t, tc.target)
+			execPath := installation(t)
+
+			opts := options(srv, execPath)
+			opts.CurrentVersion = tc.current
+			opts.Force = tc.force
+
+			_, err := Run(opts)
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("update failed: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tc.wantErr)
+			}
+			if read(t, execPath) != "old binary" {
+				t.Error("a refused update must not touch the binary")
+			}
+		})
+	}
+}
+
+func TestArchiveWithoutTemplatesRefuses(t *testing.T) {
+
+	files := map[string]string{packageBinary("linux", "amd64"): "new"}
+	archive := tarGz(t, packageDir("linux", "amd64"), files)
+	sum := sha256.Sum256(archive)
+
+	rel := &release{files: map[string][]byte{
+		ArchiveName("linux", "amd64"): archive,
+		ChecksumsFile:                 []byte(hex.EncodeToString(sum[:]) + "  " + ArchiveName("linux", "amd64") + "\n"),
+	}}
+
+	execPath := installation(t)
+
+	if _, err := Run(options(rel.serve(t, "v1.1.0"), execPath)); err == nil {
+		t.Fatal("a package without templates/base must not be installed")
+	}
+	if read(t, execPath) != "old binary" {
+		t.Error("the binary must be left alone")
+	}
+}
+
+func TestExtractRejectsPathTraversal(t *testing.T) {
+
+	archive := tarGz(t, "..", map[string]string{"escape": "x"})
+
+	if err := extractTarGz(archive, t.TempDir()); err == nil {
+		t.Error("an entry escaping the staging directory must be rejected")
+	}
+}
+
+func TestLookupChecksum(t *testing.T) {
+
+	sums := []byte("abc123  gomicrogen-linux-amd64.tar.gz\nDEF456 *gomicrogen-windows-amd64.zip\n\nmalformed line here\n")
+
+	cases := map[string]string{
+		"gomicrogen-linux-amd64.tar.gz": "abc123",
+		"gomicrogen-windows-amd64.zip":  "def456",
+	}
+
+	for archive, want := range cases {
+		got, err := lookupChecksum(sums, archive)
+		if err != nil || got != want {
+			t.Errorf("lookupChecksum(%s) = %q, %v, want %q", archive, got, err, want)
+		}
+	}
+
+	if _, err := lookupChecksum(sums, "gomicrogen-darwin-arm64.tar.gz"); err == nil {
+		t.Error("an absent archive must be an error")
+	}
+}
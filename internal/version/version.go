@@ -0,0 +1,100 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parsed is a dotted numeric version with an optional pre-release marker.
+type parsed struct {
+	parts      []int
+	prerelease bool
+}
+
+// parse accepts v1.2.3, 1.2, 1.2.3-rc1 and 1.24rc1. A version that does not
+// start with a digit (after an optional v) is not a version: "dev" builds and
+// empty strings report false.
+func parse(v string) (parsed, bool) {
+
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if v == "" || v[0] < '0' || v[0] > '9' {
+		return parsed{}, false
+	}
+
+	var p parsed
+
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		p.prerelease = v[i] == '-'
+		v = v[:i]
+	}
+
+	for _, part := range strings.Split(v, ".") {
+
+		end := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+		if end >= 0 {
+
+			// Go spells pre-releases without a separator: go1.24rc1
+			p.prerelease = true
+			part = part[:end]
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed{}, false
+		}
+
+		p.parts = append(p.parts, n)
+	}
+
+	return p, true
+}
+
+// Valid reports whether v can be compared.
+func Valid(v string) bool {
+
+	_, ok := parse(v)
+
+	return ok
+}
+
+// Compare returns -1, 0 or 1 as a is older than, equal to or newer than b.
+// Missing components count as zero, and a pre-release is older than the
+// release it precedes. Unparseable versions compare as equal.
+func Compare(a, b string) int {
+
+	pa, okA := parse(a)
+	pb, okB := parse(b)
+
+	if !okA || !okB {
+		return 0
+	}
+
+	for i := 0; i < len(pa.parts) || i < len(pb.parts); i++ {
+
+		x, y := component(pa.parts, i), component(pb.parts, i)
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case pa.prerelease && !pb.prerelease:
+		return -1
+	case !pa.prerelease && pb.prerelease:
+		return 1
+	}
+
+	return 0
+}
+
+func component(parts []int, i int) int {
+
+	if i >= len(parts) {
+		return 0
+	}
+
+	return parts[i]
+}
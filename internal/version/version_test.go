@@ -0,0 +1,50 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.24.0", "1.24.0", 0},
+		{"1.24", "1.24.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.23.9", "1.24.0", -1},
+		{"1.25.0", "1.24.9", 1},
+		{"1.100.0", "1.24.0", 1},
+		{"v1.10.0", "v1.9.9", 1},
+		// a pre-release is older than the release it precedes
+		{"v1.2.3-rc1", "v1.2.3", -1},
+		{"1.24rc1", "1.24.0", -1},
+		{"v1.2.4-rc1", "v1.2.3", 1},
+		// build metadata is not a pre-release
+		{"1.2.3+build5", "1.2.3", 0},
+	}
+
+	for _, tc := range cases {
+		if got := Compare(tc.a, tc.b); got != tc.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+
+	for v, want := range map[string]bool{
+		"v1.2.3":     true,
+		"1.24":       true,
+		"1.2.3-rc1":  true,
+		"dev":        false,
+		"":           false,
+		"latest":     false,
+		"v1.x.3":     false,
+		"vv1.2.3":    false,
+		" v1.0.0 \n": true,
+	} {
+		if got := Valid(v); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
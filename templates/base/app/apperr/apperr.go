@@ -0,0 +1,253 @@
+// Package apperr is the one error vocabulary HTTP and gRPC handlers share.
+// A handler returns an *Error saying what went wrong; HTTPErrorHandler renders
+// it as RFC 7807 problem+json and the gRPC interceptor as a status, so the
+// same failure reads the same on both.
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Kind is what went wrong, as a client should understand it.
+type Kind string
+
+const (
+	KindValidation   Kind = "validation"
+	KindNotFound     Kind = "not-found"
+	KindConflict     Kind = "conflict"
+	KindUnauthorized Kind = "unauthorized"
+	KindForbidden    Kind = "forbidden"
+	KindRateLimited  Kind = "rate-limited"
+	KindUpstream     Kind = "upstream"
+	KindInternal     Kind = "internal"
+
+	// KindUnimplemented is an operation the service declares but does not
+	// serve yet, such as a stubbed rpc
+	KindUnimplemented Kind = "unimplemented"
+)
+
+// kinds maps each Kind to its HTTP status, gRPC code and problem title.
+var kinds = map[Kind]struct {
+	status int
+	code   codes.Code
+	title  string
+}{
+	KindValidation:    {http.StatusBadRequest, codes.InvalidArgument, "Invalid request"},
+	KindNotFound:      {http.StatusNotFound, codes.NotFound, "Not found"},
+	KindConflict:      {http.StatusConflict, codes.AlreadyExists, "Conflict"},
+	KindUnauthorized:  {http.StatusUnauthorized, codes.Unauthenticated, "Unauthorized"},
+	KindForbidden:     {http.StatusForbidden, codes.PermissionDenied, "Forbidden"},
+	KindRateLimited:   {http.StatusTooManyRequests, codes.ResourceExhausted, "Too many requests"},
+	KindUpstream:      {http.StatusBadGateway, codes.Unavailable, "Upstream failure"},
+	KindInternal:      {http.StatusInternalServerError, codes.Internal, "Internal error"},
+	KindUnimplemented: {http.StatusNotImplemented, codes.Unimplemented, "Not implemented"},
+}
+
+// HTTPStatus is the status a Kind is answered with over HTTP.
+func (k Kind) HTTPStatus() int {
+
+	if m, ok := kinds[k]; ok {
+		return m.status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Code is the status code a Kind is answered with over gRPC.
+func (k Kind) Code() codes.Code {
+
+	if m, ok := kinds[k]; ok {
+		return m.code
+	}
+
+	return codes.Internal
+}
+
+// Error is an application error. Detail is shown to the client; Err, the
+// cause, is only logged.
+type Error struct {
+	Kind   Kind
+	Detail string
+
+	// Fields are the invalid input of a validation error, by field name
+	Fields map[string]string
+
+	Err error
+
+	// status overrides the Kind's HTTP status, for Status
+	status int
+}
+
+func (e *Error) Error() string {
+
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Kind, e.Detail, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Kind, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+
+	return e.Err
+}
+
+// Wrap records the cause of e, for the logs.
+func (e *Error) Wrap(err error) *Error {
+
+	e.Err = err
+
+	return e
+}
+
+func newf(kind Kind, format string, args ...any) *Error {
+
+	return &Error{Kind: kind, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Validation is input the service refuses. fields, which may be nil, names
+// what is wrong with each field.
+func Validation(fields map[string]string, format string, args ...any) *Error {
+
+	e := newf(KindValidation, format, args...)
+	e.Fields = fields
+
+	return e
+}
+
+func NotFound(format string, args ...any) *Error {
+
+	return newf(KindNotFound, format, args...)
+}
+
+// Conflict is a request at odds with the current state, such as a duplicate.
+func Conflict(format string, args ...any) *Error {
+
+	return newf(KindConflict, format, args...)
+}
+
+// Unauthorized is a caller the service could not identify.
+func Unauthorized(format string, args ...any) *Error {
+
+	return newf(KindUnauthorized, format, args...)
+}
+
+// Forbidden is a caller identified but not allowed.
+func Forbidden(format string, args ...any) *Error {
+
+	return newf(KindForbidden, format, args...)
+}
+
+func RateLimited(format string, args ...any) *Error {
+
+	return newf(KindRateLimited, format, args...)
+}
+
+// Upstream is a dependency, such as another service, failing; err is why.
+func Upstream(service string, err error) *Error {
+
+	return newf(KindUpstream, "%s is unavailable", service).Wrap(err)
+}
+
+// Unimplemented is an operation that is declared but not served yet.
+func Unimplemented(format string, args ...any) *Error {
+
+	return newf(KindUnimplemented, format, args...)
+}
+
+// Internal is a failure the client can do nothing about. Its cause is logged
+// and never shown.
+func Internal(err error) *Error {
+
+	return newf(KindInternal, "the request could not be completed").Wrap(err)
+}
+
+// Status is the error for an HTTP status, for code that only has one. The
+// status is kept, so a 405 is still answered with a 405.
+func Status(code int, detail string) *Error {
+
+	e := &Error{Kind: KindInternal, Detail: detail, status: code}
+
+	for k, m := range kinds {
+		if m.status == code {
+
+			e.Kind = k
+
+			return e
+		}
+	}
+
+	switch {
+	case code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout:
+		e.Kind = KindUpstream
+	case code >= 400 && code < 500:
+		e.Kind = KindValidation
+	}
+
+	return e
+}
+
+// HTTPStatus is the status e is answered with over HTTP.
+func (e *Error) HTTPStatus() int {
+
+	if e.status != 0 {
+		return e.status
+	}
+
+	return e.Kind.HTTPStatus()
+}
+
+// From is err as an *Error: an *Error as is, echo's own errors by their
+// status, a gRPC status from another service by its code, anything else
+// internal.
+func From(err error) *Error {
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+
+		detail := http.StatusText(he.Code)
+		if msg, ok := he.Message.(string); ok {
+			detail = msg
+		}
+
+		return Status(he.Code, detail).Wrap(err)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return newf(KindUpstream, "a dependency did not answer in time").Wrap(err)
+	}
+
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		return fromCode(s).Wrap(err)
+	}
+
+	return Internal(err)
+}
+
+// fromCode keeps what another service said a client did wrong, and treats
+// everything else as that service failing.
+func fromCode(s *status.Status) *Error {
+
+	switch s.Code() {
+	case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated, codes.PermissionDenied, codes.ResourceExhausted:
+		for k, m := range kinds {
+			if m.code == s.Code() {
+				return &Error{Kind: k, Detail: s.Message()}
+			}
+		}
+	}
+
+	return newf(KindUpstream, "a dependency failed")
+}
@@ -0,0 +1,164 @@
+package apperr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serve answers GET /wallets/42 with whatever handler returns.
+func serve(t *testing.T, handler echo.HandlerFunc) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/wallets/:id", handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/wallets/42", nil)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+	req = req.WithContext(trace.ContextWithSpanContext(req.Context(), sc))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("body %q is not a problem: %v", rec.Body, err)
+	}
+
+	return rec, p
+}
+
+func TestHTTPErrorHandlerRendersProblems(t *testing.T) {
+
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		kind   Kind
+		detail string
+	}{
+		{"not found", NotFound("wallet %d does not exist", 42), http.StatusNotFound, KindNotFound, "wallet 42 does not exist"},
+		{"conflict", Conflict("the reference is already used"), http.StatusConflict, KindConflict, "the reference is already used"},
+		{"unauthorized", Unauthorized("missing token"), http.StatusUnauthorized, KindUnauthorized, "missing token"},
+		{"wrapped", fmt.Errorf("debit: %w", Forbidden("not your wallet")), http.StatusForbidden, KindForbidden, "not your wallet"},
+		{"upstream", Upstream("wallet-service", errors.New("dial tcp: refused")), http.StatusBadGateway, KindUpstream, "wallet-service is unavailable"},
+		{"bare error", errors.New("sql: connection reset by peer"), http.StatusInternalServerError, KindInternal, "the request could not be completed"},
+		{"echo error", echo.NewHTTPError(http.StatusUnsupportedMediaType, "send JSON"), http.StatusUnsupportedMediaType, KindValidation, "send JSON"},
+		{"upstream status", status.Error(codes.Internal, "panic in ledger"), http.StatusBadGateway, KindUpstream, "a dependency failed"},
+		{"upstream not found", status.Error(codes.NotFound, "no such wallet"), http.StatusNotFound, KindNotFound, "no such wallet"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			rec, p := serve(t, func(echo.Context) error { return tc.err })
+
+			if rec.Code != tc.status || p.Status != tc.status {
+				t.Errorf("status = %d, body %d, want %d", rec.Code, p.Status, tc.status)
+			}
+			if got := rec.Header().Get(echo.HeaderContentType); got != ContentType {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if p.Type != "urn:problem:"+string(tc.kind) || p.Detail != tc.detail || p.Instance != "/wallets/42" {
+				t.Errorf("problem = %+v", p)
+			}
+			if p.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || rec.Header().Get("trace-id") != p.TraceID {
+				t.Errorf("trace ID = %q, header %q", p.TraceID, rec.Header().Get("trace-id"))
+			}
+			if strings.Contains(rec.Body.String(), "connection reset") || strings.Contains(rec.Body.String(), "panic") {
+				t.Errorf("a cause leaked to the client: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestValidationProblemListsFields(t *testing.T) {
+
+	rec, p := serve(t, func(echo.Context) error {
+		return Validation(map[string]string{"amount": "must be positive"}, "the transfer is invalid")
+	})
+
+	if rec.Code != http.StatusBadRequest || p.Errors["amount"] != "must be positive" || p.Title != "Invalid request" {
+		t.Errorf("%d %+v", rec.Code, p)
+	}
+}
+
+func TestUnknownRouteIsAProblem(t *testing.T) {
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `"type":"urn:problem:not-found"`) {
+		t.Errorf("%d %s", rec.Code, rec.Body)
+	}
+}
+
+func unary(t *testing.T, err error) *status.Status {
+	t.Helper()
+
+	_, got := UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/wallet.Wallet/Debit"}, func(context.Context, any) (any, error) {
+		return nil, err
+	})
+
+	s, ok := status.FromError(got)
+	if !ok {
+		t.Fatalf("%v is not a status", got)
+	}
+
+	return s
+}
+
+func TestGRPCSharesTheVocabulary(t *testing.T) {
+
+	for err, want := range map[error]codes.Code{
+		NotFound("wallet 42 does not exist"):             codes.NotFound,
+		Conflict("duplicate"):                            codes.AlreadyExists,
+		Unauthorized("missing token"):                    codes.Unauthenticated,
+		RateLimited("slow down"):                         codes.ResourceExhausted,
+		Unimplemented("Wallet.Deposit is not served"):    codes.Unimplemented,
+		Upstream("wallet-service", errors.New("eof")):    codes.Unavailable,
+		errors.New("sql: connection reset by peer"):      codes.Internal,
+		status.Error(codes.FailedPrecondition, "closed"): codes.FailedPrecondition,
+	} {
+		if s := unary(t, err); s.Code() != want {
+			t.Errorf("%v = %s, want %s", err, s.Code(), want)
+		}
+	}
+
+	if s := unary(t, errors.New("sql: connection reset by peer")); strings.Contains(s.Message(), "connection reset") {
+		t.Errorf("a cause leaked to the client: %s", s.Message())
+	}
+}
+
+func TestGRPCValidationCarriesFields(t *testing.T) {
+
+	s := unary(t, Validation(map[string]string{"amount": "must be positive", "currency": "unknown"}, "invalid"))
+
+	if s.Code() != codes.InvalidArgument || len(s.Details()) != 1 {
+		t.Fatalf("%s %v", s.Code(), s.Details())
+	}
+
+	bad, ok := s.Details()[0].(*errdetails.BadRequest)
+	if !ok || len(bad.GetFieldViolations()) != 2 || bad.GetFieldViolations()[0].GetField() != "amount" {
+		t.Errorf("details = %v", s.Details())
+	}
+}
@@ -0,0 +1,90 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCStatus is e as a gRPC status, so a gRPC handler can return an *Error as
+// is. A validation error carries its fields as a BadRequest detail; an
+// internal error never shows its cause.
+func (e *Error) GRPCStatus() *status.Status {
+
+	s := status.New(e.Kind.Code(), e.Detail)
+	if len(e.Fields) == 0 {
+		return s
+	}
+
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	bad := &errdetails.BadRequest{}
+	for _, field := range fields {
+		bad.FieldViolations = append(bad.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: field, Description: e.Fields[field]})
+	}
+
+	if detailed, err := s.WithDetails(bad); err == nil {
+		return detailed
+	}
+
+	return s
+}
+
+// grpcError is what a gRPC handler's err is answered with. A status the
+// handler chose is kept; anything else goes through From, so a bare error is
+// an internal one and its cause is only logged.
+func grpcError(ctx context.Context, method string, err error) error {
+
+	var e *Error
+	if !errors.As(err, &e) {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+	}
+
+	e = From(err)
+
+	if e.Kind.Code() == codes.Internal || e.Kind.Code() == codes.Unavailable {
+		logrus.WithContext(ctx).WithError(e).WithField("method", method).Error("request failed")
+	}
+
+	return e.GRPCStatus().Err()
+}
+
+// UnaryServerInterceptor answers unary handlers' errors through the same
+// vocabulary as HTTPErrorHandler.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+		res, err := handler(ctx, req)
+		if err != nil {
+			return nil, grpcError(ctx, info.FullMethod, err)
+		}
+
+		return res, nil
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streams.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		if err := handler(srv, ss); err != nil {
+			return grpcError(ss.Context(), info.FullMethod, err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package apperr
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContentType is the media type of a Problem.
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// TraceID finds the request in the traces and logs
+	TraceID string `json:"trace_id,omitempty"`
+
+	// Errors are the invalid fields of a validation problem
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Problem is e as it is shown to a client. An internal error never shows its
+// cause.
+func (e *Error) Problem() Problem {
+
+	status := e.HTTPStatus()
+
+	title := kinds[e.Kind].title
+	if title == "" || e.status != 0 {
+		title = http.StatusText(status)
+	}
+
+	return Problem{
+		Type:   "urn:problem:" + string(e.Kind),
+		Title:  title,
+		Status: status,
+		Detail: e.Detail,
+		Errors: e.Fields,
+	}
+}
+
+// HTTPErrorHandler is echo's HTTPErrorHandler. Whatever a handler or
+// middleware returns is answered as problem+json carrying the trace ID, and
+// server errors are logged with their cause.
+func HTTPErrorHandler(err error, c echo.Context) {
+
+	if c.Response().Committed {
+		return
+	}
+
+	e := From(err)
+
+	p := e.Problem()
+	p.Instance = c.Request().URL.Path
+
+	if sc := trace.SpanContextFromContext(c.Request().Context()); sc.HasTraceID() {
+
+		p.TraceID = sc.TraceID().String()
+		c.Response().Header().Set("trace-id", p.TraceID)
+	}
+
+	if p.Status >= http.StatusInternalServerError {
+		logrus.WithContext(c.Request().Context()).WithError(e).WithField("path", p.Instance).Error("request failed")
+	}
+
+	if c.Request().Method == http.MethodHead {
+
+		if err := c.NoContent(p.Status); err != nil {
+			c.Logger().Error(err)
+		}
+
+		return
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, ContentType)
+
+	if err := c.JSON(p.Status, p); err != nil {
+		c.Logger().Error(err)
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/hex"
+	"os"
+	"strings"
+)
+
+// APIKey is an AES key api-key tokens are encrypted with, found by its ID, the
+// kid a token names.
+type APIKey struct {
+	ID  string
+	Key []byte
+}
+
+// LoadAPIKeys reads only the api-key variables, for the apikey command: a token
+// is issued with API_KEYS alone, not the whole service's configuration.
+func LoadAPIKeys() (Auth, error) {
+
+	return LoadAPIKeysFrom(os.LookupEnv)
+}
+
+// LoadAPIKeysFrom is LoadAPIKeys through lookup.
+func LoadAPIKeysFrom(lookup func(string) (string, bool)) (Auth, error) {
+
+	l := &loader{lookup: lookup}
+
+	var a Auth
+	l.apiKeys(&a)
+
+	if len(l.problems) > 0 {
+		return a, &ValidationError{Problems: l.problems}
+	}
+
+	return a, nil
+}
+
+// apiKeys reads API_KEYS, a comma-separated list of kid=hex, and API_KEY_ID,
+// then the legacy API_ENCRYPTION_KEY. A key is 16, 24 or 32 bytes, hex
+// encoded, for AES-128, -192 or -256.
+func (l *loader) apiKeys(a *Auth) {
+
+	seen := map[string]bool{}
+
+	for i, entry := range strings.Split(l.secret("API_KEYS", false), ",") {
+
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		// a kid is a part of the token, which is dot separated
+		id, material, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || strings.Contains(id, ".") {
+
+			l.problem("API_KEYS: entry %d is not kid=hex, with no dot in the kid", i+1)
+
+			continue
+		}
+
+		if seen[id] {
+			l.problem("API_KEYS: kid %q is used twice", id)
+		}
+		seen[id] = true
+
+		key, ok := aesKey(material)
+		if !ok {
+
+			l.problem("API_KEYS: %s: the key is not 16, 24 or 32 bytes of hex", id)
+
+			continue
+		}
+
+		a.APIKeys = append(a.APIKeys, APIKey{ID: id, Key: key})
+	}
+
+	a.APIKeyID = l.str("API_KEY_ID", "")
+
+	switch {
+	case a.APIKeyID == "" && len(a.APIKeys) > 0:
+		a.APIKeyID = a.APIKeys[0].ID
+	case a.APIKeyID != "" && !seen[a.APIKeyID]:
+		l.problem("API_KEY_ID: %q is not in API_KEYS", a.APIKeyID)
+	}
+
+	if legacy := l.secret("API_ENCRYPTION_KEY", false); legacy != "" {
+
+		key, ok := aesKey(legacy)
+		if !ok {
+			l.problem("API_ENCRYPTION_KEY: the key is not 16, 24 or 32 bytes of hex")
+		}
+
+		a.LegacyAPIKey = key
+	}
+}
+
+func aesKey(material string) ([]byte, bool) {
+
+	key, err := hex.DecodeString(strings.TrimSpace(material))
+	if err != nil {
+		return nil, false
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, true
+	}
+
+	return nil, false
+}
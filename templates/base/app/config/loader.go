@@ -0,0 +1,255 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every missing or invalid variable, so a bad deploy is
+// fixed in one go rather than one restart per typo.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// variable is one environment variable as it was read, for Print.
+type variable struct {
+	key       string
+	value     string
+	defaulted bool
+	secret    bool
+}
+
+// loader reads variables through lookup, recording each and collecting
+// problems instead of stopping at the first.
+type loader struct {
+	lookup    func(string) (string, bool)
+	variables []variable
+	problems  []string
+}
+
+// raw returns the trimmed value of key, or def when it is unset or blank.
+func (l *loader) raw(key, def string, secret bool) (string, bool) {
+
+	value, ok := l.lookup(key)
+	value = strings.TrimSpace(value)
+
+	if !ok || value == "" {
+
+		l.variables = append(l.variables, variable{key: key, value: def, defaulted: true, secret: secret})
+
+		return def, false
+	}
+
+	l.variables = append(l.variables, variable{key: key, value: value, secret: secret})
+
+	return value, true
+}
+
+func (l *loader) problem(format string, args ...any) {
+
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+func (l *loader) str(key, def string) string {
+
+	value, _ := l.raw(key, def, false)
+
+	return value
+}
+
+func (l *loader) required(key string) string {
+
+	value, set := l.raw(key, "", false)
+	if !set {
+		l.problem("%s is required", key)
+	}
+
+	return value
+}
+
+// secret is a string Print never shows.
+func (l *loader) secret(key string, required bool) string {
+
+	value, set := l.raw(key, "", true)
+	if required && !set {
+		l.problem("%s is required", key)
+	}
+
+	return value
+}
+
+// integer reads a whole number no smaller than min.
+func (l *loader) integer(key string, def, min int) int {
+
+	value, set := l.raw(key, strconv.Itoa(def), false)
+	if !set {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+
+		l.problem("%s: %q is not a whole number", key, value)
+
+		return def
+	}
+
+	if n < min {
+		l.problem("%s: %d is below the minimum of %d", key, n, min)
+	}
+
+	return n
+}
+
+// seconds reads a positive number of seconds.
+func (l *loader) seconds(key string, def time.Duration) time.Duration {
+
+	return time.Duration(l.integer(key, int(def/time.Second), 1)) * time.Second
+}
+
+// port reads a required TCP port, or one defaulting to def when def is set.
+func (l *loader) port(key, def string) string {
+
+	var value string
+	if def == "" {
+		value = l.required(key)
+	} else {
+		value = l.str(key, def)
+	}
+
+	if value == "" {
+		return value
+	}
+
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		l.problem("%s: %q is not a port", key, value)
+	}
+
+	return value
+}
+
+func (l *loader) boolean(key string, def bool) bool {
+
+	value, set := l.raw(key, strconv.FormatBool(def), false)
+	if !set {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+
+		l.problem("%s: %q is not true or false", key, value)
+
+		return def
+	}
+
+	return b
+}
+
+// list reads a comma-separated list, dropping blank entries.
+func (l *loader) list(key, def string) []string {
+
+	value, _ := l.raw(key, def, false)
+
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// secondsList reads a comma-separated list of positive numbers of seconds.
+func (l *loader) secondsList(key, def string) []time.Duration {
+
+	var durations []time.Duration
+
+	for _, item := range l.list(key, def) {
+
+		n, err := strconv.Atoi(item)
+		if err != nil || n < 1 {
+
+			l.problem("%s: %q is not a positive number of seconds", key, item)
+
+			continue
+		}
+
+		durations = append(durations, time.Duration(n)*time.Second)
+	}
+
+	return durations
+}
+
+// cidrs reads a comma-separated list of networks. A bare address is a
+// network of one.
+func (l *loader) cidrs(key, def string) []netip.Prefix {
+
+	var prefixes []netip.Prefix
+
+	for _, item := range l.list(key, def) {
+
+		if addr, err := netip.ParseAddr(item); err == nil {
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+
+			l.problem("%s: %q is not an address or CIDR", key, item)
+
+			continue
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes
+}
+
+// oneOf reads a string that must be one of allowed.
+func (l *loader) oneOf(key, def string, allowed ...string) string {
+
+	value := l.str(key, def)
+
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+
+	l.problem("%s: %q is not one of %s", key, value, strings.Join(allowed, ", "))
+
+	return value
+}
+
+func (l *loader) print(w io.Writer) {
+
+	for _, v := range l.variables {
+
+		value := v.value
+		if v.secret && value != "" {
+			value = "[redacted]"
+		}
+
+		if v.defaulted {
+			fmt.Fprintf(w, "%s=%s (default)\n", v.key, value)
+		} else {
+			fmt.Fprintf(w, "%s=%s\n", v.key, value)
+		}
+	}
+}
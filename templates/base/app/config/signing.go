@@ -0,0 +1,218 @@
+package config
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Signature schemes. An HMAC key is a secret shared with the other side; an
+// RSA or ECDSA key is the other side's public key, to verify with, or this
+// service's private key, to sign and verify with.
+const (
+	SchemeHMACSHA256   = "hmac-sha256"
+	SchemeHMACSHA512   = "hmac-sha512"
+	SchemeRSASHA256    = "rsa-sha256"
+	SchemeRSAPSSSHA256 = "rsa-pss-sha256"
+	SchemeECDSASHA256  = "ecdsa-sha256"
+)
+
+var schemes = []string{SchemeHMACSHA256, SchemeHMACSHA512, SchemeRSASHA256, SchemeRSAPSSSHA256, SchemeECDSASHA256}
+
+// Signing is how callbacks to this service are verified and its responses
+// signed.
+type Signing struct {
+	// Keys are every key in use, so one can be rotated in before the old
+	// one is removed
+	Keys []SigningKey
+
+	// KeyID names the key responses are signed with
+	KeyID string
+
+	// MaxAge is how far a signature's timestamp may be from now, either way;
+	// each nonce is remembered for twice as long
+	MaxAge time.Duration
+}
+
+// SigningKey is one key, found by its ID, the kid a signature names.
+type SigningKey struct {
+	ID     string
+	Scheme string
+
+	// Secret is an HMAC key's
+	Secret []byte
+
+	// Public verifies an RSA or ECDSA signature; Private, when this service
+	// holds it, signs
+	Public  crypto.PublicKey
+	Private crypto.Signer
+}
+
+// CanSign reports whether this service can sign with k.
+func (k SigningKey) CanSign() bool {
+
+	return len(k.Secret) > 0 || k.Private != nil
+}
+
+// signing reads SIGNING_KEYS, a comma-separated list of kid=scheme:material.
+// An HMAC key's material is the secret itself; an RSA or ECDSA key's is its
+// PEM, base64 encoded onto one line.
+func (l *loader) signing() Signing {
+
+	s := Signing{MaxAge: l.seconds("SIGNATURE_MAX_AGE", 5*time.Minute)}
+
+	seen := map[string]bool{}
+
+	for i, entry := range strings.Split(l.secret("SIGNING_KEYS", false), ",") {
+
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		// key material never goes into a problem, so only the position and kid
+		// say which entry is wrong
+		id, rest, ok := strings.Cut(entry, "=")
+		scheme, material, ok2 := strings.Cut(rest, ":")
+		if !ok || !ok2 || id == "" || material == "" {
+
+			l.problem("SIGNING_KEYS: entry %d is not kid=scheme:material", i+1)
+
+			continue
+		}
+
+		if seen[id] {
+			l.problem("SIGNING_KEYS: kid %q is used twice", id)
+		}
+		seen[id] = true
+
+		key, err := parseSigningKey(id, scheme, material)
+		if err != nil {
+
+			l.problem("SIGNING_KEYS: %s: %v", id, err)
+
+			continue
+		}
+
+		s.Keys = append(s.Keys, key)
+	}
+
+	s.KeyID = l.str("SIGNING_KEY_ID", "")
+
+	if s.KeyID == "" {
+		for _, key := range s.Keys {
+			if key.CanSign() {
+
+				s.KeyID = key.ID
+
+				break
+			}
+		}
+
+		return s
+	}
+
+	for _, key := range s.Keys {
+		if key.ID == s.KeyID {
+
+			if !key.CanSign() {
+				l.problem("SIGNING_KEY_ID: %q is a public key, so it cannot sign", s.KeyID)
+			}
+
+			return s
+		}
+	}
+
+	l.problem("SIGNING_KEY_ID: %q is not in SIGNING_KEYS", s.KeyID)
+
+	return s
+}
+
+func parseSigningKey(id, scheme, material string) (SigningKey, error) {
+
+	key := SigningKey{ID: id, Scheme: scheme}
+
+	switch scheme {
+	case SchemeHMACSHA256, SchemeHMACSHA512:
+
+		key.Secret = []byte(material)
+
+		return key, nil
+
+	case SchemeRSASHA256, SchemeRSAPSSSHA256, SchemeECDSASHA256:
+
+	default:
+		return key, fmt.Errorf("scheme %q is not one of %s", scheme, strings.Join(schemes, ", "))
+	}
+
+	der, err := base64.StdEncoding.DecodeString(material)
+	if err != nil {
+		return key, errors.New("the key is not base64")
+	}
+
+	block, _ := pem.Decode(der)
+	if block == nil {
+		return key, errors.New("the key is not PEM")
+	}
+
+	parsed, err := parsePEM(block)
+	if err != nil {
+		return key, err
+	}
+
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.Private = signer
+		parsed = signer.Public()
+	}
+
+	key.Public = parsed
+
+	switch parsed.(type) {
+	case *rsa.PublicKey:
+		if scheme == SchemeECDSASHA256 {
+			return key, fmt.Errorf("an RSA key cannot be used for %s", scheme)
+		}
+	case *ecdsa.PublicKey:
+		if scheme != SchemeECDSASHA256 {
+			return key, fmt.Errorf("an ECDSA key cannot be used for %s", scheme)
+		}
+	default:
+		return key, fmt.Errorf("a %T cannot be used for %s", parsed, scheme)
+	}
+
+	return key, nil
+}
+
+// parsePEM reads a public key, a certificate's or a private key.
+func parsePEM(block *pem.Block) (any, error) {
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return cert.PublicKey, nil
+
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	return nil, fmt.Errorf("a %s block is not a key", block.Type)
+}
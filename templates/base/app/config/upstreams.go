@@ -0,0 +1,11 @@
+package config
+
+// Upstreams are the gRPC endpoints, host:port, of the services this one calls.
+// gomicrogen add client adds one for each client it wires.
+type Upstreams struct {
+}
+
+func (l *loader) upstreams() Upstreams {
+
+	return Upstreams{}
+}
@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServer serves the registry as the standard grpc.health.v1 service. The
+// empty service name is the whole service, SERVING when it is ready; a
+// registered dependency's name is that dependency alone.
+func (r *Registry) GRPCServer() healthpb.HealthServer {
+
+	return &grpcServer{registry: r}
+}
+
+type grpcServer struct {
+	healthpb.UnimplementedHealthServer
+
+	registry *Registry
+}
+
+func (s *grpcServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+
+	st, ok := s.status(ctx, req.GetService())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+// Watch sends the status now and again whenever it changes, checking once per
+// cache TTL until the client goes away.
+func (s *grpcServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+
+	ctx := stream.Context()
+
+	interval := s.registry.cacheTTL
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		last healthpb.HealthCheckResponse_ServingStatus
+		sent bool
+	)
+
+	for {
+
+		st, ok := s.status(ctx, req.GetService())
+		if !ok {
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+
+		if !sent || st != last {
+
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+
+			last, sent = st, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *grpcServer) status(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+
+	report := s.registry.Check(ctx)
+
+	if service == "" {
+		return serving(report.Status), true
+	}
+
+	for _, res := range report.Checks {
+		if res.Name == service {
+			return serving(res.Status), true
+		}
+	}
+
+	return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+}
+
+func serving(status string) healthpb.HealthCheckResponse_ServingStatus {
+
+	if status == StatusUp {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// GRPC checks an upstream gRPC service through its grpc.health.v1 service.
+// An upstream that does not implement it counts as up while the connection
+// is usable.
+func GRPC(conn *grpc.ClientConn) Checker {
+
+	client := healthpb.NewHealthClient(conn)
+
+	return func(ctx context.Context) error {
+
+		res, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+
+		switch {
+		case status.Code(err) == codes.Unimplemented:
+
+			if state := conn.GetState(); state == connectivity.TransientFailure || state == connectivity.Shutdown {
+				return fmt.Errorf("connection is %s", state)
+			}
+
+			return nil
+
+		case err != nil:
+			return err
+
+		case res.GetStatus() != healthpb.HealthCheckResponse_SERVING:
+			return fmt.Errorf("upstream reports %s", res.GetStatus())
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,289 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Status of a dependency, or of the service as a whole.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// defaultTimeout bounds a check that registered none.
+const defaultTimeout = 2 * time.Second
+
+// Checker reports whether a dependency is usable. It must give up when ctx is
+// done.
+type Checker func(ctx context.Context) error
+
+// Check is one dependency the service needs.
+type Check struct {
+	Name string
+
+	// Critical dependencies make the service unready when they are down;
+	// the others are only reported
+	Critical bool
+
+	// Timeout bounds Run, defaulting to the registry's
+	Timeout time.Duration
+
+	Run Checker
+}
+
+// Result is the outcome of one Check.
+type Result struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Critical bool          `json:"critical"`
+	Error    string        `json:"error,omitempty"`
+	Latency  time.Duration `json:"latency_ns"`
+}
+
+// Report is one round of checks.
+type Report struct {
+	Service string    `json:"service"`
+	Status  string    `json:"status"`
+	Checked time.Time `json:"checked_at"`
+	Checks  []Result  `json:"checks"`
+}
+
+// Ready reports whether every critical dependency is up.
+func (r Report) Ready() bool {
+
+	return r.Status == StatusUp
+}
+
+// Registry holds the dependency checks and the latest round of results. Each
+// round runs the checks concurrently and is reused for cacheTTL, so probes,
+// gRPC health clients and Prometheus scrapes share it rather than each
+// hitting the database.
+type Registry struct {
+	service  string
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	checks   []Check
+	last     Report
+	draining bool
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// New creates an empty registry. timeout bounds checks that set none.
+func New(service string, timeout, cacheTTL time.Duration) *Registry {
+
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Registry{
+		service:  service,
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+		now:      time.Now,
+	}
+}
+
+// Register adds a dependency. Registering a name twice replaces the check.
+func (r *Registry) Register(c Check) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c.Timeout <= 0 {
+		c.Timeout = r.timeout
+	}
+
+	for i := range r.checks {
+		if r.checks[i].Name == c.Name {
+
+			r.checks[i] = c
+			r.last = Report{}
+
+			return
+		}
+	}
+
+	r.checks = append(r.checks, c)
+	r.last = Report{}
+}
+
+// Drain marks the service unready for the rest of its life, so load balancers
+// and gRPC health clients stop sending work while it shuts down.
+func (r *Registry) Drain(context.Context) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.draining = true
+
+	return nil
+}
+
+// Check returns the latest round of results, running the checks again once
+// it is older than the cache TTL. Concurrent callers wait for the same round.
+func (r *Registry) Check(ctx context.Context) Report {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.last.Checked.IsZero() || r.now().Sub(r.last.Checked) >= r.cacheTTL {
+
+		// the round is shared, so one caller hanging up must not fail it
+		r.last = r.run(context.WithoutCancel(ctx))
+	}
+
+	report := r.last
+	report.Checks = append([]Result(nil), r.last.Checks...)
+
+	if r.draining {
+		report.Status = StatusDown
+	}
+
+	return report
+}
+
+// run executes every check at once, each within its own timeout.
+func (r *Registry) run(ctx context.Context) Report {
+
+	report := Report{
+		Service: r.service,
+		Status:  StatusUp,
+		Checked: r.now(),
+		Checks:  make([]Result, len(r.checks)),
+	}
+
+	var wg sync.WaitGroup
+
+	for i, c := range r.checks {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			report.Checks[i] = runCheck(ctx, c)
+		}()
+	}
+
+	wg.Wait()
+
+	for _, res := range report.Checks {
+		if res.Critical && res.Status == StatusDown {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+func runCheck(ctx context.Context, c Check) Result {
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	started := time.Now()
+
+	// a checker that ignores ctx must not hold the whole round up
+	done := make(chan error, 1)
+
+	go func() {
+
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+
+		done <- c.Run(ctx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("no answer within %s", c.Timeout)
+	}
+
+	res := Result{
+		Name:     c.Name,
+		Status:   StatusUp,
+		Critical: c.Critical,
+		Latency:  time.Since(started),
+	}
+
+	if err != nil {
+
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+var (
+	upDesc = prometheus.NewDesc(
+		"health_dependency_up",
+		"Whether a dependency passed its last health check (1) or not (0).",
+		[]string{"dependency", "critical"}, nil,
+	)
+
+	latencyDesc = prometheus.NewDesc(
+		"health_dependency_check_seconds",
+		"How long the last health check of a dependency took.",
+		[]string{"dependency"}, nil,
+	)
+
+	readyDesc = prometheus.NewDesc(
+		"health_ready",
+		"Whether every critical dependency is up (1) or not (0).",
+		nil, nil,
+	)
+)
+
+// Describe implements prometheus.Collector.
+func (r *Registry) Describe(ch chan<- *prometheus.Desc) {
+
+	ch <- upDesc
+	ch <- latencyDesc
+	ch <- readyDesc
+}
+
+// Collect implements prometheus.Collector: a scrape reads the cached round,
+// checking again only when it is stale.
+func (r *Registry) Collect(ch chan<- prometheus.Metric) {
+
+	report := r.Check(context.Background())
+
+	for _, res := range report.Checks {
+
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, gauge(res.Status), res.Name, fmt.Sprint(res.Critical))
+		ch <- prometheus.MustNewConstMetric(latencyDesc, prometheus.GaugeValue, res.Latency.Seconds(), res.Name)
+	}
+
+	ch <- prometheus.MustNewConstMetric(readyDesc, prometheus.GaugeValue, gauge(report.Status))
+}
+
+func gauge(status string) float64 {
+
+	if status == StatusUp {
+		return 1
+	}
+
+	return 0
+}
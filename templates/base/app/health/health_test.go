@@ -0,0 +1,158 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func up(context.Context) error { return nil }
+
+func down(context.Context) error { return errors.New("connection refused") }
+
+func TestCriticalDependencyDecidesReadiness(t *testing.T) {
+
+	r := New("svc", time.Second, 0)
+	r.Register(Check{Name: "database", Critical: true, Run: up})
+	r.Register(Check{Name: "wallet-service", Run: down})
+
+	report := r.Check(context.Background())
+
+	if !report.Ready() {
+		t.Errorf("a non-critical dependency being down must not make the service unready: %+v", report)
+	}
+	if report.Checks[1].Status != StatusDown || report.Checks[1].Error != "connection refused" {
+		t.Errorf("wallet-service = %+v, want it reported down with the error", report.Checks[1])
+	}
+
+	r.Register(Check{Name: "database", Critical: true, Run: down})
+
+	if report := r.Check(context.Background()); report.Ready() {
+		t.Errorf("a critical dependency is down, want unready: %+v", report)
+	}
+}
+
+func TestChecksAreCachedForTheTTL(t *testing.T) {
+
+	var calls atomic.Int32
+
+	now := time.Unix(0, 0)
+
+	r := New("svc", time.Second, 2*time.Second)
+	r.now = func() time.Time { return now }
+	r.Register(Check{Name: "database", Critical: true, Run: func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+
+	r.Check(context.Background())
+	r.Check(context.Background())
+
+	now = now.Add(time.Second)
+	r.Check(context.Background())
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("checked %d times within the TTL, want 1", got)
+	}
+
+	now = now.Add(2 * time.Second)
+	r.Check(context.Background())
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("checked %d times, want a second round once the TTL passed", got)
+	}
+}
+
+func TestSlowCheckTimesOut(t *testing.T) {
+
+	r := New("svc", time.Second, 0)
+
+	// ignores ctx, as a misbehaving driver might
+	r.Register(Check{Name: "redis", Critical: true, Timeout: 20 * time.Millisecond, Run: func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}})
+
+	started := time.Now()
+	report := r.Check(context.Background())
+
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("a round took %s, want it bounded by the check's timeout", elapsed)
+	}
+	if report.Ready() || !strings.Contains(report.Checks[0].Error, "no answer within 20ms") {
+		t.Errorf("redis = %+v, want it down for timing out", report.Checks[0])
+	}
+}
+
+func TestDrainMakesTheServiceUnready(t *testing.T) {
+
+	r := New("svc", time.Second, time.Minute)
+	r.Register(Check{Name: "database", Critical: true, Run: up})
+
+	if !r.Check(context.Background()).Ready() {
+		t.Fatal("want ready before draining")
+	}
+
+	if err := r.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Check(context.Background()).Ready() {
+		t.Error("want unready once draining, even with a cached round")
+	}
+}
+
+func TestCollectExposesEveryDependency(t *testing.T) {
+
+	r := New("svc", time.Second, 0)
+	r.Register(Check{Name: "database", Critical: true, Run: up})
+	r.Register(Check{Name: "rabbitmq", Run: down})
+
+	want := `
+# HELP health_dependency_up Whether a dependency passed its last health check (1) or not (0).
+# TYPE health_dependency_up gauge
+health_dependency_up{critical="false",dependency="rabbitmq"} 0
+health_dependency_up{critical="true",dependency="database"} 1
+# HELP health_ready Whether every critical dependency is up (1) or not (0).
+# TYPE health_ready gauge
+health_ready 1
+`
+
+	if err := testutil.CollectAndCompare(r, strings.NewReader(want), "health_dependency_up", "health_ready"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGRPCServerAnswersForTheServiceAndEachDependency(t *testing.T) {
+
+	r := New("svc", time.Second, 0)
+	r.Register(Check{Name: "database", Critical: true, Run: up})
+	r.Register(Check{Name: "wallet-service", Run: down})
+
+	s := r.GRPCServer()
+
+	for service, want := range map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":               healthpb.HealthCheckResponse_SERVING,
+		"database":       healthpb.HealthCheckResponse_SERVING,
+		"wallet-service": healthpb.HealthCheckResponse_NOT_SERVING,
+	} {
+
+		res, err := s.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q): %v", service, err)
+		}
+		if res.GetStatus() != want {
+			t.Errorf("Check(%q) = %s, want %s", service, res.GetStatus(), want)
+		}
+	}
+
+	if _, err := s.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "nope"}); err == nil {
+		t.Error("an unknown service must be NotFound")
+	}
+}
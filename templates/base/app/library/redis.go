@@ -18,7 +18,7 @@ func GetRedisKey(conn *redis.Client, key string) (string, error) {
 	data, err := conn.Get(key).Result()
 	if err != nil {
 
-		return data, fmt.Errorf("error getting key %s: %v", key, err)
+		return data, fmt.Errorf("error getting key %s: %w", key, err)
 	}
 
 	return data, err
@@ -76,3 +76,26 @@ func IncRedisKey(conn *redis.Client, key string) (int64, error) {
 
 	return data, err
 }
+
+// SetRedisKeyIfNotExists sets key to value for seconds unless key is already
+// set, and reports whether it did.
+func SetRedisKeyIfNotExists(conn *redis.Client, key string, value string, seconds int) (bool, error) {
+
+	set, err := conn.SetNX(key, value, time.Second*time.Duration(seconds)).Result()
+	if err != nil {
+
+		return false, fmt.Errorf("error setting key %s: %w", key, err)
+	}
+
+	return set, nil
+}
+
+func DeleteRedisKey(conn *redis.Client, key string) error {
+
+	if _, err := conn.Del(key).Result(); err != nil {
+
+		return fmt.Errorf("error deleting key %s: %w", key, err)
+	}
+
+	return nil
+}
@@ -2,11 +2,7 @@ package library
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
-	"log"
 	"math"
 )
 
@@ -29,23 +25,6 @@ func round(num float64) int {
 	return int(num + math.Copysign(0.5, num))
 }
 
-// GenerateSignature calculates an HMAC-SHA256 signature for the given request body using AUTH_TOKEN.
-func GetSignature(key string, body string) string {
-	return computeHmacSha256(body, key)
-}
-
-func computeHmacSha256(message, secret string) string {
-
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write([]byte(message))
-
-	res := hex.EncodeToString(h.Sum(nil))
-
-	log.Printf("HMAC-SHA256 of %s with secret %s = %s", message, secret, res)
-
-	return res
-}
-
 func NormalizeJSON(input []byte) (string, error) {
 	var raw json.RawMessage // Preserve the raw JSON structure
 	err := json.Unmarshal(input, &raw)
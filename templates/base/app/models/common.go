@@ -1,10 +1,5 @@
 package models
 
-type ErrorResponse struct {
-	ErrorCode    int    `json:"error_code" validate:"required"`
-	ErrorMessage string `json:"error_message" validate:"required"`
-}
-
 type SuccessResponse struct {
 	Status  int         `json:"status" validate:"required"`
 	Message string      `json:"message" validate:"required"`
@@ -17,12 +12,12 @@ type ResponseMessage struct {
 }
 
 type PaginationFilters struct {
-	Page    int64  `json:"page" form:"page" query:"page"`
-	PerPage int64  `json:"per_page" form:"per_page" query:"per_page"`
+	Page    int64  `json:"page" form:"page" query:"page" validate:"omitempty,min=1"`
+	PerPage int64  `json:"per_page" form:"per_page" query:"per_page" validate:"omitempty,min=1"`
 	Sort    string `json:"sort" form:"sort" query:"sort"`
 	Start   string `json:"start" form:"start" query:"start"`
 	End     string `json:"end" form:"end" query:"end"`
-	Period  int64  `json:"period" form:"period" query:"period"`
+	Period  int64  `json:"period" form:"period" query:"period" validate:"omitempty,min=1"`
 }
 
 type Pagination struct {
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the buckets in this process. Each replica counts on its
+// own, so N replicas allow N times the limit; use it for local development or
+// a single instance.
+type MemoryStore struct {
+	mu    sync.Mutex
+	tats  map[string]time.Time
+	swept time.Time
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// sweepEvery is how often full buckets are forgotten.
+const sweepEvery = time.Minute
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+
+	return &MemoryStore{
+		tats: map[string]time.Time{},
+		now:  time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Decision, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	// a bucket whose tat has passed is full, the same as one never used
+	if now.Sub(s.swept) > sweepEvery {
+
+		for k, tat := range s.tats {
+			if tat.Before(now) {
+				delete(s.tats, k)
+			}
+		}
+
+		s.swept = now
+	}
+
+	d, tat := gcra(now, s.tats[key], limit)
+	s.tats[key] = tat
+
+	return d, nil
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per
+// second.
+type Limit struct {
+	Rate  int
+	Burst int
+}
+
+// interval is how often the bucket gains a token.
+func (l Limit) interval() time.Duration {
+
+	return time.Second / time.Duration(max(l.Rate, 1))
+}
+
+// window is how long an empty bucket takes to fill.
+func (l Limit) window() time.Duration {
+
+	return l.interval() * time.Duration(max(l.Burst, 1))
+}
+
+// Decision is whether one request may go ahead, and what the caller is told
+// in the RateLimit-* and Retry-After headers.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// RetryAfter is how long a denied caller must wait for a token
+	RetryAfter time.Duration
+
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store takes a token for key. Each key is its own bucket; a store shared by
+// every replica makes the limit hold across all of them.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// gcra is the generic cell rate algorithm both stores use. tat is the
+// theoretical arrival time the bucket has reached: a request is allowed while
+// it is no more than a full window ahead of now. It returns the new tat, which
+// is unchanged when the request is denied.
+func gcra(now, tat time.Time, limit Limit) (Decision, time.Time) {
+
+	interval, window := limit.interval(), limit.window()
+
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(interval)
+	ahead := next.Sub(now)
+
+	d := Decision{Limit: max(limit.Burst, 1)}
+
+	if ahead > window {
+
+		d.RetryAfter = ahead - window
+		d.Reset = tat.Sub(now)
+
+		return d, tat
+	}
+
+	d.Allowed = true
+	d.Remaining = int((window - ahead) / interval)
+	d.Reset = ahead
+
+	return d, next
+}
+
+// seconds rounds up to whole seconds for a header, never below one.
+func seconds(d time.Duration) int {
+
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
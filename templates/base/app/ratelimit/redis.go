@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// gcraScript is gcra run atomically inside Redis, on Redis' own clock so
+// replicas with drifting clocks still agree. Times are in milliseconds, so a
+// Rate above 1000 a second is treated as 1000.
+var gcraScript = redis.NewScript(`
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+
+local interval = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then
+	tat = now
+end
+
+local ahead = tat + interval - now
+if ahead > window then
+	return {0, ahead - window, tat - now}
+end
+
+redis.call('SET', KEYS[1], tat + interval, 'PX', ahead)
+return {1, window - ahead, ahead}
+`)
+
+// RedisStore keeps the buckets in Redis, shared by every replica.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore stores buckets under prefix, which should name the service
+// so services sharing a Redis never share a bucket.
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+
+	interval := max(limit.interval().Milliseconds(), 1)
+	window := interval * int64(max(limit.Burst, 1))
+
+	res, err := gcraScript.Run(s.client.WithContext(ctx), []string{s.prefix + key}, interval, window).Result()
+	if err != nil {
+		return Decision{}, fmt.Errorf("rate limit %s: %w", key, err)
+	}
+
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 3 {
+		return Decision{}, fmt.Errorf("rate limit %s: unexpected reply %v", key, res)
+	}
+
+	n := make([]int64, len(values))
+	for i, v := range values {
+		if n[i], ok = v.(int64); !ok {
+			return Decision{}, fmt.Errorf("rate limit %s: unexpected reply %v", key, res)
+		}
+	}
+
+	d := Decision{Allowed: n[0] == 1, Limit: max(limit.Burst, 1)}
+
+	if d.Allowed {
+
+		d.Remaining = int(n[1] / interval)
+		d.Reset = time.Duration(n[2]) * time.Millisecond
+
+		return d, nil
+	}
+
+	d.RetryAfter = time.Duration(n[1]) * time.Millisecond
+	d.Reset = time.Duration(n[2]) * time.Millisecond
+
+	return d, nil
+}
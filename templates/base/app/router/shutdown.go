@@ -0,0 +1,95 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// stopFunc releases one part of the service, giving up when ctx is done.
+type stopFunc struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// lifecycle coordinates shutdown. Servers and consumers are drained first and
+// together, so nothing new comes in while in-flight work finishes. The
+// resources that work uses are then closed, last opened first.
+type lifecycle struct {
+	mu        sync.Mutex
+	drains    []stopFunc
+	resources []stopFunc
+}
+
+// onDrain registers something that takes work in: stop should stop accepting
+// and wait for what it already accepted.
+func (l *lifecycle) onDrain(name string, stop func(ctx context.Context) error) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.drains = append(l.drains, stopFunc{name: name, stop: stop})
+}
+
+// onClose registers a resource to close once every drain has finished.
+func (l *lifecycle) onClose(name string, stop func() error) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.resources = append(l.resources, stopFunc{name: name, stop: func(context.Context) error { return stop() }})
+}
+
+// Shutdown drains, then closes, within ctx's deadline. Every step runs even
+// when an earlier one fails or the deadline passes, so connections are always
+// released; the errors are joined.
+func (l *lifecycle) Shutdown(ctx context.Context) error {
+
+	l.mu.Lock()
+	drains := append([]stopFunc(nil), l.drains...)
+	resources := append([]stopFunc(nil), l.resources...)
+	l.mu.Unlock()
+
+	errs := make([]error, len(drains))
+
+	var wg sync.WaitGroup
+
+	for i, d := range drains {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			errs[i] = run(ctx, d)
+		}()
+	}
+
+	wg.Wait()
+
+	for i := len(resources) - 1; i >= 0; i-- {
+		errs = append(errs, run(ctx, resources[i]))
+	}
+
+	return errors.Join(errs...)
+}
+
+func run(ctx context.Context, s stopFunc) error {
+
+	start := time.Now()
+
+	if err := s.stop(ctx); err != nil {
+
+		log.Printf("shutdown: %s failed after %s... %v", s.name, time.Since(start).Round(time.Millisecond), err)
+
+		return fmt.Errorf("%s: %w", s.name, err)
+	}
+
+	log.Printf("shutdown: %s stopped in %s", s.name, time.Since(start).Round(time.Millisecond))
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsBeforeClosingInReverse(t *testing.T) {
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	var l lifecycle
+
+	l.onClose("database", func() error { record("database"); return nil })
+	l.onClose("redis", func() error { record("redis"); return nil })
+
+	// both drains must be running at once, or neither returns
+	var started sync.WaitGroup
+	started.Add(2)
+
+	for _, name := range []string{"http", "consumers"} {
+		l.onDrain(name, func(ctx context.Context) error {
+			started.Done()
+			started.Wait()
+			record("drained")
+			return nil
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := l.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	want := []string{"drained", "drained", "redis", "database"}
+	if !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestShutdownClosesEverythingDespiteFailures(t *testing.T) {
+
+	var l lifecycle
+
+	closed := false
+
+	l.onDrain("http", func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() })
+	l.onClose("database", func() error { closed = true; return nil })
+	l.onClose("redis", func() error { return errors.New("connection reset") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := l.Shutdown(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "redis: connection reset") {
+		t.Errorf("Shutdown = %v, want the deadline and the redis error", err)
+	}
+	if !closed {
+		t.Error("the database must be closed even after the deadline")
+	}
+}
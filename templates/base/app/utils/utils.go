@@ -6,33 +6,13 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 
 	goutils "github.com/choplife-group/go-utils"
 )
 
-func GetLanguage() string {
-
-	language := os.Getenv("LANGUAGE")
-
-	if len(language) == 0 {
-		language = "fr"
-	}
-
-	return language
-}
-
-func GetCurrency() string {
-
-	currency := os.Getenv("CURRENCY")
-
-	if len(currency) == 0 {
-		currency = "XOF"
-	}
-
-	return currency
-}
+// Debug logs outgoing requests. main sets it from config.Observability.Debug.
+var Debug bool
 
 func HTTPGet(remoteURL string, headers map[string]string, payload map[string]string) (httpStatus int, response string) {
 
@@ -49,7 +29,7 @@ func HTTPGet(remoteURL string, headers map[string]string, payload map[string]str
 		remoteURL = fmt.Sprintf("%s?%s", remoteURL, params)
 	}
 
-	if os.Getenv("debug") == "1" || os.Getenv("DEBUG") == "1" {
+	if Debug {
 		log.Printf("Wants to GET data to URL... %s", remoteURL)
 	}
 
@@ -0,0 +1,99 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMessageCarriesTheTrace(t *testing.T) {
+
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	msg, err := message(ctx, map[string]int{"amount": 500}, 3)
+	if err != nil {
+		t.Fatalf("message: %v", err)
+	}
+
+	if msg.ContentType != "application/json" || string(msg.Body) != `{"amount":500}` {
+		t.Errorf("message is %s %s, want the payload as JSON", msg.ContentType, msg.Body)
+	}
+	if msg.DeliveryMode != amqp.Persistent || msg.Priority != 3 {
+		t.Errorf("delivery mode %d, priority %d, want persistent with the priority given", msg.DeliveryMode, msg.Priority)
+	}
+	if msg.MessageId == "" || msg.Timestamp.IsZero() {
+		t.Errorf("message id %q at %s, want both set", msg.MessageId, msg.Timestamp)
+	}
+
+	want := "00-01020300000000000000000000000000-0405060000000000-01"
+	if got := msg.Headers["traceparent"]; got != want {
+		t.Errorf("traceparent = %v, want %s", got, want)
+	}
+
+	again, _ := message(ctx, nil, 0)
+	if again.MessageId == msg.MessageId {
+		t.Error("two messages share an id")
+	}
+}
+
+// confirmation is a publish the broker acks, nacks, or never answers.
+type confirmation struct {
+	acked, hangs bool
+}
+
+func (c confirmation) WaitContext(ctx context.Context) (bool, error) {
+
+	if c.hangs {
+
+		<-ctx.Done()
+
+		return false, ctx.Err()
+	}
+
+	return c.acked, nil
+}
+
+func TestUnroutablePublishFails(t *testing.T) {
+
+	returns := make(chan amqp.Return, 1)
+
+	if err := delivered(context.Background(), confirmation{acked: true}, returns, "m-1", time.Second); err != nil {
+		t.Errorf("routed: %v", err)
+	}
+
+	// the broker acks a message it returned; the return comes first
+	returns <- amqp.Return{MessageId: "m-2", Exchange: "payments", ReplyText: "NO_ROUTE"}
+
+	if err := delivered(context.Background(), confirmation{acked: true}, returns, "m-2", time.Second); !errors.Is(err, ErrUnroutable) {
+		t.Errorf("returned = %v, want ErrUnroutable", err)
+	}
+
+	// another message's return is not this one's
+	returns <- amqp.Return{MessageId: "m-1"}
+
+	if err := delivered(context.Background(), confirmation{acked: true}, returns, "m-3", time.Second); err != nil {
+		t.Errorf("a stale return failed the message: %v", err)
+	}
+
+	if err := delivered(context.Background(), confirmation{}, returns, "m-4", time.Second); err == nil {
+		t.Error("nacked message reported as published")
+	}
+
+	if err := delivered(context.Background(), confirmation{hangs: true}, returns, "m-5", 10*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unconfirmed = %v, want the timeout", err)
+	}
+}
@@ -0,0 +1,141 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DeadLetter is a message in a dead-letter queue, and why it is there.
+type DeadLetter struct {
+	MessageID string
+	Retries   int
+	Body      []byte
+
+	// Reason, Queue and Died are from the broker's latest x-death entry:
+	// rejected, expired or maxlen, in which queue, and when
+	Reason string
+	Queue  string
+	Died   time.Time
+}
+
+func deadLetter(d amqp.Delivery) DeadLetter {
+
+	l := DeadLetter{MessageID: d.MessageId, Retries: Retries(d), Body: d.Body}
+
+	deaths, _ := d.Headers["x-death"].([]interface{})
+	if len(deaths) == 0 {
+		return l
+	}
+
+	// the broker puts the latest death first
+	if death, ok := deaths[0].(amqp.Table); ok {
+
+		l.Reason, _ = death["reason"].(string)
+		l.Queue, _ = death["queue"].(string)
+		l.Died, _ = death["time"].(time.Time)
+	}
+
+	return l
+}
+
+// PeekDeadLetters returns up to limit messages from queue's dead-letter
+// queue, leaving them there. queue is the name QueueName gives.
+func PeekDeadLetters(conn *amqp.Connection, queue string, limit int) ([]DeadLetter, error) {
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	// closing the channel returns every message taken, unacked, to the queue
+	defer ch.Close()
+
+	var letters []DeadLetter
+
+	for len(letters) < limit {
+
+		d, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return letters, err
+		}
+		if !ok {
+			break
+		}
+
+		letters = append(letters, deadLetter(d))
+	}
+
+	return letters, nil
+}
+
+// ReplayDeadLetters moves up to limit messages from queue's dead-letter queue
+// back to queue, for its handler to try again with its retries reset. A
+// message leaves the dead-letter queue only once the broker has confirmed it
+// back on queue. It returns how many were moved.
+func ReplayDeadLetters(ctx context.Context, conn *amqp.Connection, queue string, limit int) (int, error) {
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return 0, err
+	}
+
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return 0, fmt.Errorf("confirm mode: %w", err)
+	}
+
+	moved := 0
+
+	for moved < limit {
+
+		d, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return moved, err
+		}
+		if !ok {
+			break
+		}
+
+		headers := amqp.Table{}
+		for k, v := range d.Headers {
+			if k != "x-death" && k != retriesHeader {
+				headers[k] = v
+			}
+		}
+
+		confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, queue, queue, false, false, amqp.Publishing{
+			Headers:         headers,
+			ContentType:     d.ContentType,
+			ContentEncoding: d.ContentEncoding,
+			DeliveryMode:    amqp.Persistent,
+			Priority:        d.Priority,
+			CorrelationId:   d.CorrelationId,
+			ReplyTo:         d.ReplyTo,
+			MessageId:       d.MessageId,
+			Timestamp:       d.Timestamp,
+			Type:            d.Type,
+			AppId:           d.AppId,
+			Body:            d.Body,
+		})
+		if err != nil {
+			return moved, errors.Join(err, d.Nack(false, true))
+		}
+
+		if acked, err := confirm.WaitContext(ctx); err != nil || !acked {
+			return moved, errors.Join(fmt.Errorf("broker did not take message %d back", moved+1), err, d.Nack(false, true))
+		}
+
+		if err := d.Ack(false); err != nil {
+			return moved, err
+		}
+
+		moved++
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,265 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DirectReplyTo is the pseudo-queue the broker routes replies through,
+// straight to the channel that sent the request.
+const DirectReplyTo = "amq.rabbitmq.reply-to"
+
+// ErrorHeader carries a reply's error, set by the handler that failed it.
+const ErrorHeader = "x-error"
+
+// ErrReplyLost is returned by Call when the connection drops before the
+// reply arrives. The request may or may not have been handled.
+var ErrReplyLost = errors.New("connection lost before the reply")
+
+// RemoteError is the error a request's handler replied with.
+type RemoteError struct {
+	Queue   string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+
+	return fmt.Sprintf("%s: %s", e.Queue, e.Message)
+}
+
+// rpcChannel is what the client needs of an AMQP channel; *amqp.Channel is
+// one.
+type rpcChannel interface {
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	NotifyReturn(c chan amqp.Return) chan amqp.Return
+	Close() error
+}
+
+// reply is how a call ends: the reply's body, the error its handler replied
+// with, or why there is no reply.
+type reply struct {
+	body   []byte
+	remote string
+	err    error
+}
+
+// Client sends requests to queues served by queue.Reply handlers and waits
+// for their replies. Every call shares one channel, whose replies come back
+// through DirectReplyTo and are matched to their call by correlation ID.
+type Client struct {
+	open    func(ctx context.Context) (rpcChannel, error)
+	timeout time.Duration
+
+	// mu guards ch, which is reopened after it drops, and the calls waiting
+	// on it
+	mu      sync.Mutex
+	ch      rpcChannel
+	pending map[string]chan reply
+}
+
+// NewClient returns a client on broker's connection. A call gives up after
+// timeout, or sooner when its ctx is done.
+func NewClient(broker *Supervisor, timeout time.Duration) *Client {
+
+	return newClient(func(ctx context.Context) (rpcChannel, error) {
+
+		conn, err := broker.Connection(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return conn.Channel()
+	}, timeout)
+}
+
+func newClient(open func(context.Context) (rpcChannel, error), timeout time.Duration) *Client {
+
+	return &Client{open: open, timeout: timeout, pending: map[string]chan reply{}}
+}
+
+// Call sends msg to the exchange and routing key msg.Queue, the name
+// QueueName gives the queue, and returns the body of the reply. It fills in
+// msg.ReplyTo and, when empty, msg.CorrelationID. The request expires on the
+// broker when the call gives up, so a worker never handles a request nobody
+// waits for. A request no queue is bound for fails at once.
+func (c *Client) Call(ctx context.Context, msg Message) ([]byte, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = uuid.NewString()
+	}
+
+	msg.ReplyTo = DirectReplyTo
+
+	if msg.ContentType == "" {
+		msg.ContentType = "application/json"
+	}
+
+	replied := make(chan reply, 1)
+
+	ch, err := c.await(ctx, msg.CorrelationID, replied)
+	if err != nil {
+		return nil, fmt.Errorf("rabbitmq unavailable: %w", err)
+	}
+
+	defer c.forget(msg.CorrelationID)
+
+	headers := amqp.Table{}
+	Inject(ctx, headers)
+
+	publishing := amqp.Publishing{
+		Headers:       headers,
+		ContentType:   msg.ContentType,
+		CorrelationId: msg.CorrelationID,
+		ReplyTo:       msg.ReplyTo,
+		Priority:      msg.Priority,
+		Timestamp:     time.Now().UTC(),
+		Body:          msg.Body,
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		publishing.Expiration = strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 1), 10)
+	}
+
+	// mandatory, so a request no queue is bound for comes back at once
+	if err := ch.PublishWithContext(ctx, msg.Queue, msg.Queue, true, false, publishing); err != nil {
+		return nil, fmt.Errorf("send request to %s: %w", msg.Queue, err)
+	}
+
+	select {
+	case r := <-replied:
+
+		if r.remote != "" {
+			return nil, &RemoteError{Queue: msg.Queue, Message: r.remote}
+		}
+
+		return r.body, r.err
+
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no reply from %s: %w", msg.Queue, ctx.Err())
+	}
+}
+
+// await registers replied for the reply to id, and returns the shared
+// channel to send the request on. It opens the channel, and starts reading its
+// replies, when there is none.
+func (c *Client) await(ctx context.Context, id string, replied chan reply) (rpcChannel, error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ch != nil {
+
+		c.pending[id] = replied
+
+		return c.ch, nil
+	}
+
+	ch, err := c.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// consuming DirectReplyTo must come before the first request
+	replies, err := ch.Consume(DirectReplyTo, "", true, false, false, false, nil)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("consume replies: %w", err), ch.Close())
+	}
+
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+
+	c.ch = ch
+	c.pending[id] = replied
+
+	go c.read(ch, replies, returns)
+
+	return ch, nil
+}
+
+// read hands each reply, and each request the broker could not route, to its
+// call. When the channel drops it fails every call still waiting, and the
+// next call opens a new channel.
+func (c *Client) read(ch rpcChannel, replies <-chan amqp.Delivery, returns <-chan amqp.Return) {
+
+	for replies != nil || returns != nil {
+
+		select {
+		case d, ok := <-replies:
+
+			if !ok {
+				replies = nil
+				continue
+			}
+
+			remote, _ := d.Headers[ErrorHeader].(string)
+
+			c.deliver(d.CorrelationId, reply{body: d.Body, remote: remote})
+
+		case ret, ok := <-returns:
+
+			if !ok {
+				returns = nil
+				continue
+			}
+
+			c.deliver(ret.CorrelationId, reply{err: fmt.Errorf("no queue for %s: %s", ret.RoutingKey, ret.ReplyText)})
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ch == ch {
+		c.ch = nil
+	}
+
+	for id, replied := range c.pending {
+
+		replied <- reply{err: ErrReplyLost}
+		delete(c.pending, id)
+	}
+}
+
+// deliver ends the call waiting on id, if it is still waiting.
+func (c *Client) deliver(id string, r reply) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if replied, ok := c.pending[id]; ok {
+
+		replied <- r
+		delete(c.pending, id)
+	}
+}
+
+func (c *Client) forget(id string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, id)
+}
+
+// Reply answers d, a request a Client sent, with msg on the consumer's
+// channel.
+func (r *RabbitMQConnection) Reply(ctx context.Context, d amqp.Delivery, msg amqp.Publishing) error {
+
+	msg.CorrelationId = d.CorrelationId
+
+	if err := r.channel.PublishWithContext(ctx, "", d.ReplyTo, false, false, msg); err != nil {
+		return fmt.Errorf("reply to %s: %w", d.ReplyTo, err)
+	}
+
+	return nil
+}
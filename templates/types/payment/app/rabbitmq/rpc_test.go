@@ -0,0 +1,329 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeBroker is an in-process stand-in for RabbitMQ: each queue is served by
+// a function, and a reply reaches the channel that sent the request through
+// direct reply-to.
+type fakeBroker struct {
+	mu      sync.Mutex
+	queues  map[string]func(d amqp.Delivery)
+	callers map[string]*fakeChannel
+	opened  int
+}
+
+func newFakeBroker() *fakeBroker {
+
+	return &fakeBroker{queues: map[string]func(amqp.Delivery){}, callers: map[string]*fakeChannel{}}
+}
+
+// serve has fn handle each request to queue, concurrently.
+func (b *fakeBroker) serve(queue string, fn func(d amqp.Delivery)) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.queues[queue] = fn
+}
+
+func (b *fakeBroker) open(context.Context) (rpcChannel, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.opened++
+
+	ch := &fakeChannel{broker: b, address: fmt.Sprintf("%s.%d", DirectReplyTo, b.opened)}
+	b.callers[ch.address] = ch
+
+	return ch, nil
+}
+
+// reply answers d as queue.Reply does: with body, or with message in
+// ErrorHeader.
+func (b *fakeBroker) reply(d amqp.Delivery, body, message string) {
+
+	msg := amqp.Publishing{CorrelationId: d.CorrelationId, Body: []byte(body)}
+	if message != "" {
+		msg.Headers = amqp.Table{ErrorHeader: message}
+	}
+
+	b.mu.Lock()
+	caller := b.callers[d.ReplyTo]
+	b.mu.Unlock()
+
+	if caller != nil {
+		caller.deliver(amqp.Delivery{CorrelationId: msg.CorrelationId, Headers: msg.Headers, Body: msg.Body, RoutingKey: d.ReplyTo})
+	}
+}
+
+type fakeChannel struct {
+	broker  *fakeBroker
+	address string
+
+	mu      sync.Mutex
+	replies chan amqp.Delivery
+	returns []chan amqp.Return
+	closed  bool
+}
+
+func (c *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+
+	if queue != DirectReplyTo || !autoAck {
+		return nil, fmt.Errorf("unexpected consume of %s", queue)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.replies = make(chan amqp.Delivery, 16)
+
+	return c.replies, nil
+}
+
+func (c *fakeChannel) NotifyReturn(r chan amqp.Return) chan amqp.Return {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.returns = append(c.returns, r)
+
+	return r
+}
+
+func (c *fakeChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+
+	c.mu.Lock()
+
+	if c.closed {
+
+		c.mu.Unlock()
+
+		return amqp.ErrClosed
+	}
+
+	// the broker refuses a reply-to on a channel not consuming replies
+	if msg.ReplyTo != DirectReplyTo || c.replies == nil {
+
+		c.mu.Unlock()
+
+		return fmt.Errorf("reply-to %q without consuming %s", msg.ReplyTo, DirectReplyTo)
+	}
+
+	c.mu.Unlock()
+
+	c.broker.mu.Lock()
+	serve, ok := c.broker.queues[key]
+	c.broker.mu.Unlock()
+
+	d := amqp.Delivery{
+		Headers:       msg.Headers,
+		ContentType:   msg.ContentType,
+		CorrelationId: msg.CorrelationId,
+		ReplyTo:       c.address,
+		Expiration:    msg.Expiration,
+		Exchange:      exchange,
+		RoutingKey:    key,
+		Body:          msg.Body,
+	}
+
+	if !ok {
+
+		if mandatory {
+
+			c.mu.Lock()
+			for _, r := range c.returns {
+				r <- amqp.Return{ReplyText: "NO_ROUTE", Exchange: exchange, RoutingKey: key, CorrelationId: msg.CorrelationId}
+			}
+			c.mu.Unlock()
+		}
+
+		return nil
+	}
+
+	go serve(d)
+
+	return nil
+}
+
+func (c *fakeChannel) deliver(d amqp.Delivery) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.replies <- d
+	}
+}
+
+// Close drops the channel, as the broker does when the connection goes.
+func (c *fakeChannel) Close() error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return amqp.ErrClosed
+	}
+
+	c.closed = true
+
+	close(c.replies)
+	for _, r := range c.returns {
+		close(r)
+	}
+
+	c.broker.mu.Lock()
+	delete(c.broker.callers, c.address)
+	c.broker.mu.Unlock()
+
+	return nil
+}
+
+func TestCallsGetTheirOwnReplies(t *testing.T) {
+
+	b := newFakeBroker()
+
+	// answer later requests first, so replies come back out of order
+	b.serve("psp.quote", func(d amqp.Delivery) {
+
+		if d.Expiration == "" {
+			b.reply(d, "", "request without an expiration")
+			return
+		}
+
+		var n int
+		fmt.Sscan(string(d.Body), &n)
+		time.Sleep(time.Duration(10-n) * 5 * time.Millisecond)
+
+		b.reply(d, "fee for "+string(d.Body), "")
+	})
+
+	c := newClient(b.open, time.Second)
+
+	var wg sync.WaitGroup
+
+	for n := range 10 {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			body, err := c.Call(context.Background(), Message{Queue: "psp.quote", Body: []byte(fmt.Sprint(n))})
+			if err != nil {
+				t.Errorf("call %d: %v", n, err)
+				return
+			}
+
+			if want := fmt.Sprintf("fee for %d", n); string(body) != want {
+				t.Errorf("call %d got %q, want %q", n, body, want)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if b.opened != 1 {
+		t.Errorf("opened %d channels, want every call to share one", b.opened)
+	}
+	if len(c.pending) != 0 {
+		t.Errorf("%d calls still pending", len(c.pending))
+	}
+}
+
+func TestCallFailsWithTheHandlersError(t *testing.T) {
+
+	b := newFakeBroker()
+	b.serve("psp.quote", func(d amqp.Delivery) { b.reply(d, "", "amount must be positive") })
+
+	_, err := newClient(b.open, time.Second).Call(context.Background(), Message{Queue: "psp.quote", Body: []byte(`{}`)})
+
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Queue != "psp.quote" || remote.Message != "amount must be positive" {
+		t.Errorf("Call = %v, want the handler's error from psp.quote", err)
+	}
+}
+
+func TestCallGivesUp(t *testing.T) {
+
+	b := newFakeBroker()
+	b.serve("psp.quote", func(amqp.Delivery) {})
+
+	c := newClient(b.open, 20*time.Millisecond)
+
+	if _, err := c.Call(context.Background(), Message{Queue: "psp.quote"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unanswered Call = %v, want it to time out", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.Call(ctx, Message{Queue: "psp.quote"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled Call = %v, want context.Canceled", err)
+	}
+
+	if len(c.pending) != 0 {
+		t.Errorf("%d calls still pending after giving up", len(c.pending))
+	}
+}
+
+func TestUnroutableRequestFailsAtOnce(t *testing.T) {
+
+	start := time.Now()
+
+	_, err := newClient(newFakeBroker().open, time.Minute).Call(context.Background(), Message{Queue: "psp.missing"})
+	if err == nil || !strings.Contains(err.Error(), "no queue for psp.missing") {
+		t.Errorf("Call = %v, want the request returned unroutable", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Error("an unroutable request must not wait for the timeout")
+	}
+}
+
+func TestCallsFailWhenTheChannelDrops(t *testing.T) {
+
+	b := newFakeBroker()
+
+	received := make(chan amqp.Delivery, 1)
+	b.serve("psp.quote", func(d amqp.Delivery) { received <- d })
+
+	c := newClient(b.open, time.Second)
+
+	go func() {
+
+		<-received
+
+		c.mu.Lock()
+		ch := c.ch
+		c.mu.Unlock()
+
+		ch.Close()
+	}()
+
+	if _, err := c.Call(context.Background(), Message{Queue: "psp.quote"}); !errors.Is(err, ErrReplyLost) {
+		t.Fatalf("Call = %v, want ErrReplyLost", err)
+	}
+
+	b.serve("psp.quote", func(d amqp.Delivery) { b.reply(d, "ok", "") })
+
+	if body, err := c.Call(context.Background(), Message{Queue: "psp.quote"}); err != nil || string(body) != "ok" {
+		t.Fatalf("Call after the drop = %q, %v, want a new channel to carry it", body, err)
+	}
+
+	if b.opened != 2 {
+		t.Errorf("opened %d channels, want a second after the drop", b.opened)
+	}
+}
@@ -0,0 +1,302 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrSupervisorClosed is returned by Connection once the supervisor is closed.
+var ErrSupervisorClosed = errors.New("rabbitmq supervisor closed")
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// Supervisor owns the service's RabbitMQ connection, shared by the consumers
+// and the publisher. It dials in the background and dials again whenever the
+// connection drops, backing off while the broker is away, so the service
+// starts, and keeps running, without it. Consumers declare their topology
+// again on each new connection.
+type Supervisor struct {
+	uri       string
+	dial      func(uri string) (*amqp.Connection, error)
+	closeConn func(*amqp.Connection) error
+
+	mu   sync.Mutex
+	conn *amqp.Connection
+	err  error
+
+	// ready is closed, and replaced, when a connection opens, and closed for
+	// good by Close, waking whoever waits in Connection
+	ready  chan struct{}
+	closed bool
+
+	connects, failures int
+
+	stop     chan struct{}
+	done     chan struct{}
+	closeErr error
+}
+
+// Supervise starts supervising a connection to uri. Close it last, once the
+// consumers have drained.
+func Supervise(uri string) *Supervisor {
+
+	s := newSupervisor(uri, amqp.Dial)
+
+	go s.run()
+
+	return s
+}
+
+func newSupervisor(uri string, dial func(string) (*amqp.Connection, error)) *Supervisor {
+
+	return &Supervisor{
+		uri:       uri,
+		dial:      dial,
+		closeConn: (*amqp.Connection).Close,
+		err:       errors.New("not connected yet"),
+		ready:     make(chan struct{}),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// backoff is how long to wait after attempt+1 failed dials: doubling from
+// minBackoff up to maxBackoff, half of it random so replicas that lost the
+// broker together do not all dial again at once.
+func backoff(attempt int) time.Duration {
+
+	d := maxBackoff
+	if attempt < 16 {
+		d = min(minBackoff<<attempt, maxBackoff)
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
+
+func (s *Supervisor) run() {
+
+	defer close(s.done)
+
+	attempt := 0
+
+	for {
+
+		conn, err := s.dial(s.uri)
+		if err != nil {
+
+			wait := backoff(attempt)
+			attempt++
+
+			s.failed(err)
+
+			logrus.WithFields(logrus.Fields{
+				"description": "rabbitmq unreachable",
+				"data":        wait.String(),
+			}).Warn(err.Error())
+
+			select {
+			case <-time.After(wait):
+				continue
+			case <-s.stop:
+				return
+			}
+		}
+
+		attempt = 0
+
+		// closed without a value only by Close, which stops the loop first
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		// Close ran while the dial was in flight, so the connection is not
+		// handed out; ready and stop are closed already
+		if !s.connected(conn) {
+
+			s.close(conn)
+
+			return
+		}
+
+		select {
+		case amqpErr := <-closed:
+
+			err := errors.New("connection closed")
+			if amqpErr != nil {
+				err = amqpErr
+			}
+
+			s.failed(err)
+
+			logrus.WithFields(logrus.Fields{
+				"description": "rabbitmq connection lost, reconnecting",
+			}).Warn(err.Error())
+
+		case <-s.stop:
+
+			s.close(conn)
+
+			return
+		}
+	}
+}
+
+// connected makes conn the connection, unless the supervisor is closed.
+func (s *Supervisor) connected(conn *amqp.Connection) bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.conn = conn
+	s.err = nil
+	s.connects++
+
+	close(s.ready)
+	s.ready = make(chan struct{})
+
+	return true
+}
+
+// close closes conn as the supervisor stops, keeping the error for Close.
+func (s *Supervisor) close(conn *amqp.Connection) {
+
+	if err := s.closeConn(conn); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		s.closeErr = err
+	}
+}
+
+func (s *Supervisor) failed(err error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn = nil
+	s.err = err
+	s.failures++
+}
+
+// Connection returns the open connection, waiting for one while the broker is
+// away. It fails once ctx is done or the supervisor is closed. A connection
+// can still drop right after; watch it with NotifyClose.
+func (s *Supervisor) Connection(ctx context.Context) (*amqp.Connection, error) {
+
+	for {
+
+		s.mu.Lock()
+		conn, ready, closed := s.conn, s.ready, s.closed
+		s.mu.Unlock()
+
+		if closed {
+			return nil, ErrSupervisorClosed
+		}
+
+		if conn != nil && !conn.IsClosed() {
+			return conn, nil
+		}
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Check reports whether the connection is open, for the health registry.
+func (s *Supervisor) Check(context.Context) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil && !s.conn.IsClosed() {
+		return nil
+	}
+
+	if s.err != nil {
+		return fmt.Errorf("not connected to rabbitmq: %w", s.err)
+	}
+
+	return errors.New("not connected to rabbitmq")
+}
+
+// Close stops reconnecting and closes the connection. Connection fails from
+// then on.
+func (s *Supervisor) Close() error {
+
+	s.mu.Lock()
+
+	if s.closed {
+
+		s.mu.Unlock()
+
+		return nil
+	}
+
+	s.closed = true
+	close(s.ready)
+	close(s.stop)
+
+	s.mu.Unlock()
+
+	<-s.done
+
+	return s.closeErr
+}
+
+var (
+	connectedDesc = prometheus.NewDesc(
+		"rabbitmq_connected",
+		"Whether the RabbitMQ connection is open (1) or not (0).",
+		nil, nil,
+	)
+
+	connectsDesc = prometheus.NewDesc(
+		"rabbitmq_connects_total",
+		"How many times the RabbitMQ connection has been opened.",
+		nil, nil,
+	)
+
+	failuresDesc = prometheus.NewDesc(
+		"rabbitmq_connection_failures_total",
+		"How many dials to RabbitMQ failed, or connections to it dropped.",
+		nil, nil,
+	)
+)
+
+// Describe implements prometheus.Collector.
+func (s *Supervisor) Describe(ch chan<- *prometheus.Desc) {
+
+	ch <- connectedDesc
+	ch <- connectsDesc
+	ch <- failuresDesc
+}
+
+// Collect implements prometheus.Collector.
+func (s *Supervisor) Collect(ch chan<- prometheus.Metric) {
+
+	connected := 0.0
+	if s.Check(context.Background()) == nil {
+		connected = 1
+	}
+
+	s.mu.Lock()
+	connects, failures := s.connects, s.failures
+	s.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(connectedDesc, prometheus.GaugeValue, connected)
+	ch <- prometheus.MustNewConstMetric(connectsDesc, prometheus.CounterValue, float64(connects))
+	ch <- prometheus.MustNewConstMetric(failuresDesc, prometheus.CounterValue, float64(failures))
+}
@@ -0,0 +1,172 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestBackoffIsJitteredAndCapped(t *testing.T) {
+
+	for attempt, ceiling := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, maxBackoff, maxBackoff} {
+		for range 100 {
+			if d := backoff(attempt); d < ceiling/2 || d > ceiling {
+				t.Fatalf("backoff after %d failures = %s, want between %s and %s", attempt+1, d, ceiling/2, ceiling)
+			}
+		}
+	}
+
+	if d := backoff(1000); d > maxBackoff {
+		t.Errorf("backoff after many failures = %s, want at most %s", d, maxBackoff)
+	}
+}
+
+// unreachable is a supervisor whose every dial fails.
+func unreachable(t *testing.T) *Supervisor {
+	t.Helper()
+
+	dialled := make(chan struct{}, 1)
+
+	s := newSupervisor("amqp://broker", func(string) (*amqp.Connection, error) {
+
+		select {
+		case dialled <- struct{}{}:
+		default:
+		}
+
+		return nil, errors.New("connection refused")
+	})
+
+	go s.run()
+
+	t.Cleanup(func() { s.Close() })
+
+	<-dialled
+
+	return s
+}
+
+func TestSupervisorReportsTheBrokerDown(t *testing.T) {
+
+	s := unreachable(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := s.Connection(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Connection = %v, want it to wait for the broker until ctx is done", err)
+	}
+
+	if err := s.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Check = %v, want the dial error", err)
+	}
+
+	want := `
+# HELP rabbitmq_connected Whether the RabbitMQ connection is open (1) or not (0).
+# TYPE rabbitmq_connected gauge
+rabbitmq_connected 0
+# HELP rabbitmq_connects_total How many times the RabbitMQ connection has been opened.
+# TYPE rabbitmq_connects_total counter
+rabbitmq_connects_total 0
+`
+	if err := testutil.CollectAndCompare(s, strings.NewReader(want), "rabbitmq_connected", "rabbitmq_connects_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCloseReleasesWhoeverWaits(t *testing.T) {
+
+	s := unreachable(t)
+
+	waited := make(chan error, 1)
+
+	go func() {
+
+		_, err := s.Connection(context.Background())
+		waited <- err
+	}()
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	select {
+	case err := <-waited:
+		if !errors.Is(err, ErrSupervisorClosed) {
+			t.Errorf("Connection = %v, want ErrSupervisorClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Connection still waiting after Close")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestDialFinishingAfterCloseIsClosed(t *testing.T) {
+
+	dialling, release := make(chan struct{}), make(chan struct{})
+	late := &amqp.Connection{}
+
+	s := newSupervisor("amqp://broker", func(string) (*amqp.Connection, error) {
+
+		close(dialling)
+		<-release
+
+		return late, nil
+	})
+
+	var discarded *amqp.Connection
+	s.closeConn = func(conn *amqp.Connection) error {
+
+		discarded = conn
+
+		return nil
+	}
+
+	go s.run()
+
+	<-dialling
+
+	closed := make(chan error, 1)
+	go func() { closed <- s.Close() }()
+
+	// the dial returns only once Close has begun
+	for {
+
+		s.mu.Lock()
+		began := s.closed
+		s.mu.Unlock()
+
+		if began {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close still waiting for the late dial")
+	}
+
+	if discarded != late {
+		t.Error("the connection dialled after Close was left open")
+	}
+
+	if _, err := s.Connection(context.Background()); !errors.Is(err, ErrSupervisorClosed) {
+		t.Errorf("Connection = %v, want ErrSupervisorClosed", err)
+	}
+}
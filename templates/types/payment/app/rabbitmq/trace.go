@@ -0,0 +1,55 @@
+package rabbitmq
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+)
+
+// HeaderCarrier carries the OpenTelemetry trace context in a message's AMQP
+// headers.
+type HeaderCarrier amqp.Table
+
+// Get implements propagation.TextMapCarrier.
+func (h HeaderCarrier) Get(key string) string {
+
+	value, _ := h[key].(string)
+
+	return value
+}
+
+// Set implements propagation.TextMapCarrier.
+func (h HeaderCarrier) Set(key, value string) {
+
+	h[key] = value
+}
+
+// Keys implements propagation.TextMapCarrier.
+func (h HeaderCarrier) Keys() []string {
+
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// Inject writes ctx's trace context into headers, for the consumer to
+// continue the trace.
+func Inject(ctx context.Context, headers amqp.Table) {
+
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(headers))
+}
+
+// Extract returns ctx carrying the trace context a message was published
+// with, when its headers have one.
+func Extract(ctx context.Context, headers amqp.Table) context.Context {
+
+	if headers == nil {
+		return ctx
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(headers))
+}
@@ -0,0 +1,34 @@
+-- Events written by outbox.Write in the same transaction as the change they
+-- announce. The relay publishes unsent rows in id order, sets sent_at, and
+-- deletes them once OUTBOX_RETENTION has passed.
+{{ if .IsPostgres -}}
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    message_id UUID NOT NULL UNIQUE,
+    exchange VARCHAR(255) NOT NULL,
+    priority SMALLINT NOT NULL DEFAULT 0,
+    headers TEXT NOT NULL,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS outbox_unsent ON outbox (id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_sent_at ON outbox (sent_at);
+{{- else -}}
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    message_id CHAR(36) NOT NULL UNIQUE,
+    exchange VARCHAR(255) NOT NULL,
+    priority TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    headers TEXT NOT NULL,
+    payload LONGBLOB NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    sent_at TIMESTAMP(6) NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    INDEX outbox_sent_at (sent_at, id)
+);
+{{- end }}
@@ -256,6 +256,15 @@ func serviceEnv(driver, dbName, httpPort, grpcPort string) []string {
 func runService(t *testing.T, bin, dir string, env []string, httpPort string) string {
 	t.Helper()
 
+	_, logPath := startService(t, bin, dir, env, httpPort)
+
+	return logPath
+}
+
+// startService is runService for tests that signal the process themselves.
+func startService(t *testing.T, bin, dir string, env []string, httpPort string) (*exec.Cmd, string) {
+	t.Helper()
+
 	logPath := filepath.Join(t.TempDir(), "service.log")
 
 	logFile, err := os.Create(logPath)
@@ -284,7 +293,7 @@ func runService(t *testing.T, bin, dir string, env []string, httpPort string) st
 		t.Fatalf("service never became healthy on port %s\n--- log ---\n%s", httpPort, body)
 	}
 
-	return logPath
+	return cmd, logPath
 }
 
 func waitForHTTP(url string, timeout time.Duration) bool {
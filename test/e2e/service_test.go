@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -46,16 +47,29 @@ func TestServiceTypesRunAgainstRealDependencies(t *testing.T) {
 
 			base := "http://127.0.0.1:" + tc.httpPort
 
-			t.Run("status endpoint reports healthy dependencies", func(t *testing.T) {
+			t.Run("probes report healthy dependencies", func(t *testing.T) {
 
-				code, body := get(t, base+"/")
+				if code, _ := get(t, base+"/healthz"); code != http.StatusOK {
+					t.Errorf("GET /healthz = %d, want 200", code)
+				}
 
-				if code != http.StatusOK {
-					t.Fatalf("GET / = %d, want 200", code)
+				want := []string{`"service":"svc"`, `"name":"database","status":"up"`, `"name":"redis","status":"up"`, `"name":"global-redis","status":"up"`}
+				if tc.serviceType == "payment" {
+					want = append(want, `"name":"rabbitmq","status":"up"`)
 				}
-				for _, want := range []string{"successful ping", "PONG", "svc"} {
-					if !strings.Contains(body, want) {
-						t.Errorf("status body missing %q: %s", want, body)
+
+				// / is kept for load balancers configured before /readyz
+				for _, path := range []string{"/readyz", "/"} {
+
+					code, body := get(t, base+path)
+
+					if code != http.StatusOK {
+						t.Fatalf("GET %s = %d, want 200: %s", path, code, body)
+					}
+					for _, w := range want {
+						if !strings.Contains(body, w) {
+							t.Errorf("GET %s missing %s: %s", path, w, body)
+						}
 					}
 				}
 			})
@@ -76,12 +90,35 @@ func TestServiceTypesRunAgainstRealDependencies(t *testing.T) {
 				if !strings.Contains(body, "echo_requests_total") {
 					t.Error("metrics missing the fleet-wide echo_requests_total series")
 				}
+				if !strings.Contains(body, `health_dependency_up{critical="true",dependency="database"} 1`) {
+					t.Error("metrics missing the per-dependency health gauges")
+				}
 			})
 
 			t.Run("migrations applied", func(t *testing.T) {
 				assertMigrated(t, "mysql", mysqlDSN(dbName))
 			})
 
+			if tc.serviceType == "payment" {
+				t.Run("outbox relay runs", func(t *testing.T) {
+
+					db, err := sql.Open("mysql", mysqlDSN(dbName))
+					if err != nil {
+						t.Fatalf("open mysql: %v", err)
+					}
+					defer db.Close()
+
+					var pending int
+					if err := db.QueryRow("SELECT COUNT(*) FROM outbox WHERE sent_at IS NULL").Scan(&pending); err != nil {
+						t.Fatalf("outbox table missing: %v", err)
+					}
+
+					if _, body := get(t, base+"/metrics"); !strings.Contains(body, "outbox_pending 0") {
+						t.Error("metrics missing the outbox backlog")
+					}
+				})
+			}
+
 			t.Run("grpc server only where the type calls for it", func(t *testing.T) {
 
 				if got := portIsOpen(tc.grpcPort); got != tc.wantGRPC {
@@ -102,7 +139,7 @@ func TestRateLimiterProtectsRoutesButNotMetrics(t *testing.T) {
 	bin := compile(t, dir)
 
 	env := append(serviceEnv("mysql", dbName, "18200", "18201"),
-		"RATE_LIMIT=5", "RATE_LIMIT_BURST=2", "RATE_LIMIT_EXPIRES_IN_SECONDS=60")
+		"RATE_LIMIT=5", "RATE_LIMIT_BURST=2")
 
 	runService(t, bin, dir, env, "18200")
 
@@ -130,10 +167,75 @@ func TestRateLimiterProtectsRoutesButNotMetrics(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("probe paths are exempt", func(t *testing.T) {
+
+		for i := 0; i < 40; i++ {
+			for _, path := range []string{"/healthz", "/readyz"} {
+				if code, _ := get(t, base+path); code == http.StatusTooManyRequests {
+					t.Fatalf("%s was rate limited; a limited probe gets the pod restarted", path)
+				}
+			}
+		}
+	})
+}
+
+// Buckets live in Redis, so two replicas must share one limit rather than
+// each allowing it in full, and a denied caller is told when to come back.
+func TestRateLimitHoldsAcrossReplicas(t *testing.T) {
+
+	dbName := "svc_replicas"
+	mustCreateMySQLDatabase(t, dbName)
+
+	dir := generate(t, "svc")
+	bin := compile(t, dir)
+
+	limits := []string{"RATE_LIMIT=1", "RATE_LIMIT_BURST=3"}
+
+	runService(t, bin, dir, append(serviceEnv("mysql", dbName, "18210", "18211"), limits...), "18210")
+	runService(t, bin, dir, append(serviceEnv("mysql", dbName, "18220", "18221"), limits...), "18220")
+
+	allowed := 0
+	var denied *http.Response
+
+	for i := 0; i < 8; i++ {
+
+		port := []string{"18210", "18220"}[i%2]
+
+		resp, err := http.Get("http://127.0.0.1:" + port + "/")
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			denied = resp
+			continue
+		}
+
+		allowed++
+
+		if resp.Header.Get("RateLimit-Limit") != "3" {
+			t.Errorf("RateLimit-Limit = %q, want 3", resp.Header.Get("RateLimit-Limit"))
+		}
+	}
+
+	// the burst, plus at most one token refilled while the requests ran
+	if allowed > 4 {
+		t.Errorf("%d of 8 requests allowed across two replicas; each is counting on its own", allowed)
+	}
+
+	if denied == nil || denied.Header.Get("Retry-After") == "" {
+		t.Fatal("a denied request must carry Retry-After")
+	}
+
+	if got := denied.Header.Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("a denied request is answered as %q, want problem+json", got)
+	}
 }
 
-// The IP extractor must prefer a public forwarded address and ignore private
-// ones, otherwise the limiter keys on the proxy instead of the caller.
+// The IP extractor must walk X-Forwarded-For past the trusted hops, so the
+// limiter keys on the caller rather than the proxy or a spoofed entry.
 func TestClientIPExtraction(t *testing.T) {
 
 	dbName := "svc_clientip"
@@ -150,7 +252,8 @@ func TestClientIPExtraction(t *testing.T) {
 		wantLogged string
 	}{
 		{"public forwarded address is used", "203.0.113.9, 10.0.0.1", "203.0.113.9"},
-		{"private forwarded address is ignored", "10.1.2.3", "127.0.0.1"},
+		{"an entry the client prepended is skipped", "198.51.100.7, 203.0.113.10", "203.0.113.10"},
+		{"a chain of trusted hops resolves to the leftmost", "10.1.2.3", "10.1.2.3"},
 	}
 
 	for _, tc := range cases {
@@ -232,6 +335,50 @@ func TestServiceRunsFromRelocatedBinary(t *testing.T) {
 	}
 }
 
+// SIGTERM during a rollout must drain HTTP, gRPC and the consumers and close
+// every connection, then exit 0, rather than dying with work in flight.
+func TestSIGTERMShutsDownGracefully(t *testing.T) {
+
+	dbName := "svc_shutdown"
+	mustCreateMySQLDatabase(t, dbName)
+
+	dir := generate(t, "svc", "--type", "payment")
+	bin := compile(t, dir)
+
+	env := append(serviceEnv("mysql", dbName, "18600", "18601"), "QUEUES=withdraw", "SHUTDOWN_TIMEOUT=10")
+
+	cmd, logPath := startService(t, bin, dir, env, "18600")
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("signal: %v", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	select {
+	case err := <-exited:
+		if err != nil {
+			body, _ := os.ReadFile(logPath)
+			t.Fatalf("service exited with %v\n--- log ---\n%s", err, body)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("service did not exit within SHUTDOWN_TIMEOUT after SIGTERM")
+	}
+
+	body, _ := os.ReadFile(logPath)
+
+	for _, part := range []string{"http", "grpc", "consumers", "outbox", "rabbitmq", "redis", "global redis", "database"} {
+		if !strings.Contains(string(body), "shutdown: "+part+" stopped") {
+			t.Errorf("%s was not shut down cleanly\n--- log ---\n%s", part, body)
+		}
+	}
+
+	if portIsOpen("18600") || portIsOpen("18601") {
+		t.Error("the HTTP and gRPC ports must be released")
+	}
+}
+
 // --go-mod runs `go mod init`/`go mod tidy` inside the target. A relative
 // --output-dir once made the go.mod existence check resolve twice, so the CLI
 // ran `go mod init` on a module that already existed and aborted.
@@ -349,3 +496,63 @@ func copyDir(src, dst string) error {
 
 	return nil
 }
+
+// A service missing its settings must refuse to start and name every one, not
+// come up half-configured; --print-config must show the values but no secret.
+func TestInvalidConfigFailsFastAndPrintsRedacted(t *testing.T) {
+
+	dir := generate(t, "svc", "--type", "payment")
+	bin := compile(t, dir)
+
+	t.Run("missing and invalid values are listed together", func(t *testing.T) {
+
+		var env []string
+		for _, kv := range serviceEnv("mysql", "unused", "18700", "18701") {
+			if !strings.HasPrefix(kv, "SESSION_SECRET=") && !strings.HasPrefix(kv, "RABBITMQ_HOST=") {
+				env = append(env, kv)
+			}
+		}
+		env = append(env, "RATE_LIMIT=fast")
+
+		cmd := exec.Command(bin)
+		cmd.Dir = dir
+		cmd.Env = env
+
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Fatalf("service started without SESSION_SECRET:\n%s", out)
+		}
+
+		for _, want := range []string{"SESSION_SECRET is required", "RABBITMQ_HOST is required", `RATE_LIMIT: "fast"`} {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("output is missing %q:\n%s", want, out)
+			}
+		}
+
+		if portIsOpen("18700") {
+			t.Error("nothing should listen when the configuration is invalid")
+		}
+	})
+
+	t.Run("--print-config redacts secrets", func(t *testing.T) {
+
+		cmd := exec.Command(bin, "--print-config")
+		cmd.Dir = dir
+		cmd.Env = serviceEnv("mysql", "unused", "18700", "18701")
+
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("--print-config: %v\n%s", err, out)
+		}
+
+		if strings.Contains(string(out), "test-secret") {
+			t.Errorf("SESSION_SECRET was printed:\n%s", out)
+		}
+
+		for _, want := range []string{"SYSTEM_PORT=18700\n", "SESSION_SECRET=[redacted]\n", "RATE_LIMIT=20 (default)\n"} {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("output is missing %q:\n%s", want, out)
+			}
+		}
+	})
+}